@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAcceptance signs users in to the app through the OAuth flow of a
+// fake GitHub, and checks what they see on the home page.
+func TestAcceptance(t *testing.T) {
+	tests := []struct {
+		name string
+		// the user to sign in as, if any
+		login string
+		// the page loaded, the home page by default
+		path string
+		// what happens between signing in and loading the home page
+		then     func(g *fakeGitHub, server *httptest.Server, client *http.Client)
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "first time login",
+			login:    "webdev",
+			want:     []string{"Hey! I know you! <b>webdev</b>", "GitHub Recs:", "You starred:"},
+			unwanted: []string{"something went wrong"},
+		},
+		{
+			name:  "expired token",
+			login: "webdev",
+			then: func(g *fakeGitHub, server *httptest.Server, client *http.Client) {
+				expireCookie(client, server, "token")
+			},
+			want:     []string{"to begin!"},
+			unwanted: []string{"I know you", "something went wrong"},
+		},
+		{
+			name:  "revoked token mid-session",
+			login: "webdev",
+			then: func(g *fakeGitHub, server *httptest.Server, client *http.Client) {
+				g.revoke("webdev")
+			},
+			want:     []string{"to begin!"},
+			unwanted: []string{"I know you", "something went wrong"},
+		},
+		{
+			name:     "user with zero stars",
+			login:    "newbie",
+			want:     []string{"<b>newbie</b>", "you have not starred any repos"},
+			unwanted: []string{"GitHub Recs:"},
+		},
+		{
+			name:     "user with private stars only",
+			login:    "private-eye",
+			want:     []string{"<b>private-eye</b>", "are all on private repos", "/?from=contributions", `name="repos"`},
+			unwanted: []string{"GitHub Recs:", "private-eye/dossiers"},
+		},
+		{
+			name:     "recommendations from contributions",
+			login:    "private-eye",
+			path:     "/?from=contributions",
+			want:     []string{"GitHub Recs:", "You own or contributed to:", "fchollet/keras<"},
+			unwanted: []string{"private-eye/private", "?from=contributions"},
+		},
+		{
+			name:     "seed repos without signing in",
+			path:     "/?repos=tensorflow/tensorflow,nobody/nothing",
+			want:     []string{"repos you picked", "<b>nobody/nothing</b>", "GitHub Recs:", "You picked:", "because of"},
+			unwanted: []string{"I know you", "to begin!"},
+		},
+		{
+			// only the most recent pages are fetched
+			name:  "user with 10k stars",
+			login: "stargazer",
+			want: []string{
+				"<b>stargazer</b>",
+				"GitHub Recs:",
+				"Show all " + formatNumber(defaultLocale, starPageLimit*100) + " stars",
+				"stargazer/repo-1<",
+			},
+			unwanted: []string{"stargazer/repo-9999<"},
+		},
+		{
+			name:  "GitHub 500s",
+			login: "webdev",
+			then: func(g *fakeGitHub, server *httptest.Server, client *http.Client) {
+				g.setDown(true)
+			},
+			want:     []string{"something went wrong", "500 Internal Server Error"},
+			unwanted: []string{"I know you"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := devUsers(time.Now())
+			users["stargazer"] = manyStars(10000)
+			g := newFakeGitHub(users)
+			server, client, stop := startDevServer(t, g)
+			defer stop()
+
+			if tt.login != "" {
+				signIn(t, server, client, tt.login)
+			}
+			if tt.then != nil {
+				tt.then(g, server, client)
+			}
+
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+			resp, err := client.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			page := string(body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Wrong status: %d %s", resp.StatusCode, page)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("Missing %q", want)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(page, unwanted) {
+					t.Errorf("Unexpected %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+// manyStars returns n stars, newest first, one in ten of a repository the
+// model knows
+func manyStars(n int) []gitHubStarredResponse {
+	now := time.Now()
+	repos := currentModel().Repositories()
+	stars := make([]gitHubStarredResponse, n)
+	for i := range stars {
+		repo := fmt.Sprintf("stargazer/repo-%d", i)
+		if i%10 == 0 {
+			repo = repos[(i/10)%len(repos)]
+		}
+		stars[i] = gitHubStarredResponse{
+			StarredAt: now.Add(-time.Duration(i) * time.Hour),
+			Repo:      gitHubRepository{Repository: repo, PushedAt: now},
+		}
+	}
+	return stars
+}
+
+func expireCookie(client *http.Client, server *httptest.Server, name string) {
+	u, _ := http.NewRequest("GET", server.URL+"/", nil)
+	client.Jar.SetCookies(u.URL, []*http.Cookie{{Name: name, MaxAge: -1}})
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// accessLogDefaultRoute sets the sample rate of the routes not listed in
+// ACCESS_LOG_SAMPLING
+const accessLogDefaultRoute = "*"
+
+type (
+	// accessLogEntry is a line of the access log, in JSON, for the
+	// analytics pipeline
+	accessLogEntry struct {
+		Time   time.Time `json:"time"`
+		Route  string    `json:"route"`
+		Method string    `json:"method"`
+		Status int       `json:"status"`
+		// the time to serve the request, in milliseconds
+		Latency float64 `json:"latency_ms"`
+		// identifies the signed in user without their token or login, as
+		// a prefix of tokenDigest
+		User         string `json:"user,omitempty"`
+		ModelVersion string `json:"model_version,omitempty"`
+		// whether the response came out of a cache: a replayed idempotent
+		// request, or a query of the model computed before
+		CacheHit bool `json:"cache_hit"`
+		// the rate the route is sampled at, to weight the entry by
+		SampleRate float64 `json:"sample_rate"`
+	}
+
+	// statusRecorder keeps the status of the response it writes
+	statusRecorder struct {
+		http.ResponseWriter
+		status int
+	}
+)
+
+var (
+	// accessLogSampling is the rate each route is logged at, set with
+	// ACCESS_LOG_SAMPLING. Routes not in it are all logged, unless it
+	// sets accessLogDefaultRoute.
+	accessLogSampling = map[string]float64{}
+	accessLogger      = log.New(os.Stdout, "", 0)
+)
+
+// parseAccessLogSampling parses a comma separated list of routes and the
+// rate each is sampled at, as in "/=0.1,/api/v1/recommendations=0.01,*=1".
+// A rate of 0 turns off the log of a route.
+func parseAccessLogSampling(s string) (map[string]float64, error) {
+	sampling := map[string]float64{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		i := strings.LastIndex(field, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("missing rate of %q", field)
+		}
+		rate, err := strconv.ParseFloat(field[i+1:], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid rate of %q, expected 0 to 1", field)
+		}
+		sampling[field[:i]] = rate
+	}
+	return sampling, nil
+}
+
+// sampleRate returns the rate a route is logged at
+func sampleRate(route string) float64 {
+	if rate, ok := accessLogSampling[route]; ok {
+		return rate
+	}
+	if rate, ok := accessLogSampling[accessLogDefaultRoute]; ok {
+		return rate
+	}
+	return 1
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// withAccessLog logs a sample of the requests to a route, at its rate in
+// accessLogSampling. Server errors are always logged.
+func withAccessLog(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var cacheHit int32
+		rec := &statusRecorder{ResponseWriter: w}
+		h(rec, r.WithContext(recommender.CountCacheHits(r.Context(), &cacheHit)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		rate := sampleRate(route)
+		if rec.status < 500 && (rate == 0 || rand.Float64() >= rate) {
+			return
+		}
+		entry := accessLogEntry{
+			Time:         start.UTC(),
+			Route:        route,
+			Method:       r.Method,
+			Status:       rec.status,
+			Latency:      float64(time.Since(start)) / float64(time.Millisecond),
+			ModelVersion: w.Header().Get(modelVersionHeader),
+			CacheHit:     atomic.LoadInt32(&cacheHit) != 0 || w.Header().Get("Idempotent-Replayed") != "",
+			SampleRate:   rate,
+		}
+		if digest := tokenDigest(r); digest != "" {
+			entry.User = digest[:16]
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			logErrorf("Failed to log access: %v", err)
+			return
+		}
+		accessLogger.Print(string(line))
+	}
+}
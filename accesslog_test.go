@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAccessLogSampling(t *testing.T) {
+	sampling, err := parseAccessLogSampling("/=0.1, /api/v1/recommendations:batch=1,*=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]float64{"/": 0.1, "/api/v1/recommendations:batch": 1, "*": 0}
+	if !reflect.DeepEqual(sampling, want) {
+		t.Errorf("Wrong sampling: %v", sampling)
+	}
+	for _, s := range []string{"/", "=0.5", "/=2", "/=-1", "/=often"} {
+		if _, err := parseAccessLogSampling(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(logger *log.Logger, sampling map[string]float64) {
+		accessLogger, accessLogSampling = logger, sampling
+	}(accessLogger, accessLogSampling)
+	accessLogger = log.New(&buf, "", 0)
+	accessLogSampling = map[string]float64{"/api/v1/recommendations": 1, "*": 0}
+
+	serve := func(route string, h http.HandlerFunc, r *http.Request) []accessLogEntry {
+		buf.Reset()
+		withAccessLog(route, h)(httptest.NewRecorder(), r)
+		var entries []accessLogEntry
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry accessLogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Invalid entry %q: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	url := "/api/v1/recommendations?repos=golang/go,BVLC/caffe&n=3"
+	r := httptest.NewRequest("GET", url, nil)
+	r.AddCookie(&http.Cookie{Name: "token", Value: "secret"})
+	entries := serve("/api/v1/recommendations", apiRecommendations, r)
+	if len(entries) != 1 {
+		t.Fatalf("Wrong entries: %+v", entries)
+	}
+	entry := entries[0]
+	if entry.Route != "/api/v1/recommendations" || entry.Method != "GET" || entry.Status != http.StatusOK || entry.ModelVersion != defaultModelVersion || entry.SampleRate != 1 {
+		t.Errorf("Wrong entry: %+v", entry)
+	}
+	if len(entry.User) != 16 || strings.Contains(buf.String(), "secret") {
+		t.Errorf("Wrong user hash: %q", entry.User)
+	}
+
+	// the query of the same seeds is cached by now
+	entries = serve("/api/v1/recommendations", apiRecommendations, httptest.NewRequest("GET", url, nil))
+	if len(entries) != 1 || !entries[0].CacheHit || entries[0].User != "" {
+		t.Errorf("Wrong entry of a cached query: %+v", entries)
+	}
+
+	if entries := serve("/demo", demo, httptest.NewRequest("GET", "/demo", nil)); len(entries) != 0 {
+		t.Errorf("Logged a route sampled at 0: %+v", entries)
+	}
+	failed := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}
+	if entries := serve("/demo", failed, httptest.NewRequest("GET", "/demo", nil)); len(entries) != 1 || entries[0].Status != http.StatusInternalServerError {
+		t.Errorf("Server error not logged: %+v", entries)
+	}
+}
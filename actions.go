@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const gitHubStarPath = "/user/starred/%s"
+
+type actionErrorResponse struct {
+	Error    string `json:"error"`
+	LoginURL string `json:"login_url,omitempty"`
+}
+
+// checkScriptPost validates the requests of the buttons and keyboard
+// shortcuts of the recommendations page. They must be POSTs sent by our
+// scripts: browsers do not let other sites set the X-Requested-With
+// header.
+func checkScriptPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		httpError(w, r, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if r.Header.Get("X-Requested-With") == "" {
+		httpError(w, r, "missing X-Requested-With header", http.StatusForbidden)
+		return false
+	}
+	w.Header().Set("Cache-Control", privateCacheControl)
+	return true
+}
+
+// checkAction validates a script request acting on a repository of the
+// model.
+func checkAction(w http.ResponseWriter, r *http.Request) (repo string, ok bool) {
+	if !checkScriptPost(w, r) {
+		return "", false
+	}
+	model := currentModel()
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return "", false
+	}
+	repo = model.Repository(r.FormValue("repo"))
+	if repo == "" {
+		httpError(w, r, fmt.Sprintf("Unknown repository: %s", r.FormValue("repo")), http.StatusBadRequest)
+		return "", false
+	}
+	return repo, true
+}
+
+// star stars a recommended repository on GitHub for the user. Starring
+// needs the public_repo scope, which is not requested when signing in, so
+// the error response links to a login that grants it.
+func star(w http.ResponseWriter, r *http.Request) {
+	repo, ok := checkAction(w, r)
+	if !ok {
+		return
+	}
+	resp, err := gitHubDo(r, "PUT", gitHubAPIURL+fmt.Sprintf(gitHubStarPath, repo), "application/json")
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		w.WriteHeader(http.StatusNoContent)
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		// GitHub answers 404 when the token lacks the scope
+		missingStarScope(w, r)
+	default:
+		httpError(w, r, fmt.Sprintf("Unexpected status from GitHub: %s", resp.Status), http.StatusBadGateway)
+	}
+}
+
+// dismiss hides a recommendation for good by adding it to the exclusions
+// saved in the user's cookie.
+func dismiss(w http.ResponseWriter, r *http.Request) {
+	repo, ok := checkAction(w, r)
+	if !ok {
+		return
+	}
+	setExcludeCookie(w, addExclusion(excludeCookie(r), repo))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addExclusion adds a pattern to the exclusions, unless it is already
+// one, and drops the oldest ones past maxPatterns or maxExcludeCookie
+// bytes, as browsers drop the whole cookie when it is too large.
+func addExclusion(patterns []string, pattern string) []string {
+	for _, p := range patterns {
+		if p == pattern {
+			return patterns
+		}
+	}
+	patterns = append(patterns, pattern)
+	if len(patterns) > maxPatterns {
+		patterns = patterns[len(patterns)-maxPatterns:]
+	}
+	for len(patterns) > 1 && len(url.QueryEscape(strings.Join(patterns, ","))) > maxExcludeCookie {
+		patterns = patterns[1:]
+	}
+	return patterns
+}
+
+func missingStarScope(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusForbidden, starScopeError())
+}
+
+// starScopeError tells the user that their token cannot change their
+// stars, and how to grant it the permission
+func starScopeError() actionErrorResponse {
+	if localToken != "" {
+		// signing in again would not change the token
+		return actionErrorResponse{
+			Error: "Changing your stars needs the Starring user permission (read and write) of a fine-grained token, or the public_repo scope of a classic one",
+		}
+	}
+	return actionErrorResponse{
+		Error:    "Changing your stars needs permission to star public repositories",
+		LoginURL: loginURL("public_repo"),
+	}
+}
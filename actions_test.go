@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAddExclusion(t *testing.T) {
+	var patterns []string
+	for i := 0; i < maxPatterns+5; i++ {
+		patterns = addExclusion(patterns, fmt.Sprintf("o/r%d", i))
+	}
+	if len(patterns) != maxPatterns || patterns[0] != "o/r5" || patterns[maxPatterns-1] != fmt.Sprintf("o/r%d", maxPatterns+4) {
+		t.Errorf("The oldest exclusions were not dropped: %d %v", len(patterns), patterns[:3])
+	}
+	if again := addExclusion(patterns, "o/r5"); len(again) != maxPatterns || again[0] != "o/r5" {
+		t.Errorf("An exclusion was added twice: %v", again[:3])
+	}
+
+	long := strings.Repeat("x", 90) + "/"
+	patterns = nil
+	for i := 0; i < maxPatterns; i++ {
+		patterns = addExclusion(patterns, fmt.Sprintf("%s%d", long, i))
+	}
+	if size := len(url.QueryEscape(strings.Join(patterns, ","))); size > maxExcludeCookie || patterns[len(patterns)-1] != long+"99" {
+		t.Errorf("The exclude cookie is too large: %d bytes", size)
+	}
+}
+
+func TestDismissCapsExclusions(t *testing.T) {
+	var patterns []string
+	for i := 0; i < maxPatterns; i++ {
+		patterns = append(patterns, fmt.Sprintf("o/r%d", i))
+	}
+	r := httptest.NewRequest("POST", "/dismiss?repo=golang/go", nil)
+	r.Header.Set("X-Requested-With", "fetch")
+	r.AddCookie(&http.Cookie{Name: "exclude", Value: url.QueryEscape(strings.Join(patterns, ","))})
+	w := httptest.NewRecorder()
+	dismiss(w, r)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusNoContent || len(cookies) != 1 {
+		t.Fatalf("Wrong response: %d %v", w.Code, cookies)
+	}
+	value, _ := url.QueryUnescape(cookies[0].Value)
+	excluded := splitPatterns(value)
+	if len(excluded) != maxPatterns || excluded[0] != "o/r1" || excluded[maxPatterns-1] != "golang/go" {
+		t.Errorf("Wrong exclusions: %d %v", len(excluded), excluded[:3])
+	}
+}
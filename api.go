@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	// maxAPIRecommendations caps the n parameter of the API
+	maxAPIRecommendations = 100
+	// maxAPIStarsPerPage caps the per_page parameter of the stars API
+	maxAPIStarsPerPage = 100
+)
+
+type (
+	// apiRecommendation is a recommendation with its 1-based position in
+	// the list
+	apiRecommendation struct {
+		RepositoryScore
+		Rank int `json:"rank"`
+		// the score in the locale of the client, with formatted=true
+		Formatted map[string]string `json:"formatted,omitempty"`
+	}
+
+	apiRecommendationsResponse struct {
+		User            string              `json:"user,omitempty"`
+		Items           []string            `json:"items"`
+		Recommendations []apiRecommendation `json:"recommendations"`
+		// the seed repositories the model does not know, left out
+		Unknown []string `json:"unknown,omitempty"`
+		// the version of the model that made the recommendations
+		ModelVersion string `json:"model_version"`
+	}
+
+	// apiStar is a star of the user and how the model sees it
+	apiStar struct {
+		Repository string    `json:"repository"`
+		StarredAt  time.Time `json:"starred_at"`
+		InModel    bool      `json:"in_model"`
+		// the model entity the star resolves to, and the weight it has in
+		// the recommendations
+		Entity string  `json:"entity,omitempty"`
+		Weight float64 `json:"weight"`
+		// when it was starred relative to now, with formatted=true
+		Formatted map[string]string `json:"formatted,omitempty"`
+	}
+
+	apiStarsResponse struct {
+		User    string    `json:"user"`
+		Total   int       `json:"total"`
+		InModel int       `json:"in_model"`
+		Page    int       `json:"page"`
+		PerPage int       `json:"per_page"`
+		Stars   []apiStar `json:"stars"`
+		// the counts in the locale of the client, with formatted=true
+		Formatted map[string]string `json:"formatted,omitempty"`
+	}
+)
+
+// apiRecommendations returns recommendations as JSON, for scripts and
+// other services. The items are the seed repositories in the repos
+// parameter (comma separated, or repeated), or the stars of the signed in
+// user when there are none. Seed repositories the model does not know are
+// left out and listed as unknown, and none known is an error. The number
+// of recommendations is set with n, lang and topic restrict them to some
+// languages or topics (e.g. lang=go,rust or topic=cli), lambda
+// diversifies them with MMR, and formatted=true adds the scores formatted
+// in the locale of the client.
+func apiRecommendations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		apiError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	model, version := modelForRequest(r)
+	if model == nil {
+		apiError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	n, err := positiveFormInt(r, "n", numRecommendations, maxAPIRecommendations)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diversify, err := formMMR(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := formattedLocale(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repos, err := formList(r, "repos", maxSeeds)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := formLabels(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := apiRecommendationsResponse{ModelVersion: version}
+	if len(repos) > 0 {
+		response.Items, response.Unknown = knownRepositories(model, repos)
+		if len(response.Items) == 0 {
+			apiError(w, r, unknownRepositoriesError(response.Unknown).Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		w.Header().Set("Cache-Control", privateCacheControl)
+		user, err := authenticatedUser(r)
+		var stars []string
+		if err == nil {
+			stars, err = starred(r, time.Time{})
+		}
+		if err != nil {
+			apiError(w, r, "Unauthorized: sign in with GitHub or pass seed repositories in repos", http.StatusUnauthorized)
+			return
+		}
+		response.User = user
+		response.Items = stars
+		opts = append(opts, recommender.ExcludePatterns(excludeCookie(r)...))
+	}
+	opts = append(opts, diversify, recommender.Filters(filters...))
+
+	recs, err := model.Recommend(r.Context(), response.Items, n, opts...)
+	if err != nil {
+		apiError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusBadRequest))
+		return
+	}
+	response.Recommendations = []apiRecommendation{}
+	for i, rec := range recs {
+		response.Recommendations = append(response.Recommendations, newAPIRecommendation(rec, i+1, locale))
+	}
+	if len(repos) > 0 {
+		// only successes are cached, a failure may not last
+		setPublicCacheHeaders(w, "api")
+	}
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// newAPIRecommendation returns a recommendation at a rank, with its score
+// formatted in a locale unless it is ""
+func newAPIRecommendation(rec RepositoryScore, rank int, locale string) apiRecommendation {
+	result := apiRecommendation{RepositoryScore: rec, Rank: rank}
+	if locale != "" {
+		result.Formatted = map[string]string{"score": formatDecimal(locale, rec.Score, 2)}
+	}
+	return result
+}
+
+// apiStars lists the stars of the signed in user that feed their
+// recommendations, page by page, with whether each of them is part of the
+// model. It takes the since and window parameters of the home page, and
+// links to the next page in the Link header, like the GitHub API. With
+// formatted=true, counts and dates are also formatted for the client.
+func apiStars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	model, version := modelForRequest(r)
+	if model == nil {
+		apiError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+	since, err := starsSince(r, time.Now())
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := positiveFormInt(r, "page", 1, 0)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	perPage, err := positiveFormInt(r, "per_page", maxAPIStarsPerPage, maxAPIStarsPerPage)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := formattedLocale(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := authenticatedUser(r)
+	var repos []gitHubRepository
+	if err == nil {
+		repos, err = starredRepositories(r)
+	}
+	if err != nil {
+		apiError(w, r, "Unauthorized: sign in with GitHub", http.StatusUnauthorized)
+		return
+	}
+
+	stars := make([]apiStar, 0, len(repos))
+	inModel := 0
+	now := time.Now()
+	for _, repo := range starredSince(repos, since) {
+		star := apiStar{Repository: repo.Repository, StarredAt: repo.StarredAt, Entity: model.Repository(repo.Repository)}
+		if star.Entity != "" {
+			star.InModel = true
+			star.Weight = model.Confidence()
+			inModel++
+		}
+		if locale != "" {
+			star.Formatted = map[string]string{"starred_at": formatRelative(star.StarredAt, now)}
+		}
+		stars = append(stars, star)
+	}
+
+	response := apiStarsResponse{User: user, Total: len(stars), InModel: inModel, Page: page, PerPage: perPage, Stars: []apiStar{}}
+	if locale != "" {
+		response.Formatted = map[string]string{"total": formatNumber(locale, response.Total), "in_model": formatNumber(locale, inModel)}
+	}
+	if start := (page - 1) * perPage; start < len(stars) {
+		end := start + perPage
+		if end < len(stars) {
+			next := *r.URL
+			query := next.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+		} else {
+			end = len(stars)
+		}
+		response.Stars = stars[start:end]
+	}
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// positiveFormInt parses an optional positive integer parameter, up to max
+// unless max is 0.
+func positiveFormInt(r *http.Request, name string, value, max int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return value, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || (max > 0 && n > max) {
+		if max > 0 {
+			return 0, fmt.Errorf("Invalid %s %q, expected a number from 1 to %d", name, s, max)
+		}
+		return 0, fmt.Errorf("Invalid %s %q, expected a positive number", name, s)
+	}
+	return n, nil
+}
+
+// apiError replies with a JSON error whatever the Accept header says,
+// which shared caches do not keep, as renderError does
+func apiError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	w.Header().Del("Surrogate-Key")
+	writeJSON(w, r, status, errorResponse{message})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jbochi/github-recs/recsclient"
+)
+
+func TestAPIRecommendations(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=tensorflow/tensorflow,BVLC/caffe&n=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status: %d %s", w.Code, w.Body.String())
+	}
+	var response apiRecommendationsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(response.Recommendations) != 5 {
+		t.Fatalf("Wrong number of recommendations: %v", response.Recommendations)
+	}
+	if response.ModelVersion != defaultModelVersion || w.Header().Get(modelVersionHeader) != defaultModelVersion {
+		t.Errorf("Wrong model version: %q %q", response.ModelVersion, w.Header().Get(modelVersionHeader))
+	}
+	for i, rec := range response.Recommendations {
+		if rec.Rank != i+1 || rec.Repository == "" {
+			t.Errorf("Wrong recommendation: %+v", rec)
+		}
+	}
+
+	for _, url := range []string{"/api/v1/recommendations?repos=BVLC/caffe&n=0", "/api/v1/recommendations?repos=BVLC/caffe&n=1000"} {
+		w = httptest.NewRecorder()
+		apiRecommendations(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Wrong status for %s: %d", url, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("Wrong response without seeds or token: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestAPIErrorsAreNotCached(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=BVLC/caffe", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != publicCacheControl {
+		t.Errorf("Recommendations are not cacheable: %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=BVLC/caffe", nil).WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Cache-Control") != privateCacheControl || w.Header().Get("Surrogate-Key") != "" {
+		t.Errorf("Failure is cacheable: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAPIStarsRequiresSignIn(t *testing.T) {
+	for url, want := range map[string]int{
+		"/api/v1/stars":               http.StatusUnauthorized,
+		"/api/v1/stars?page=0":        http.StatusBadRequest,
+		"/api/v1/stars?per_page=101":  http.StatusBadRequest,
+		"/api/v1/stars?window=x":      http.StatusBadRequest,
+		"/api/v1/stars?formatted=no!": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		apiStars(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != want {
+			t.Errorf("Wrong status for %s: %d, want %d", url, w.Code, want)
+		}
+	}
+}
+
+// TestRecsClient checks that the client package decodes the responses of
+// the API
+func TestRecsClient(t *testing.T) {
+	server, _, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+	client := recsclient.New(server.URL)
+	ctx := context.Background()
+
+	recs, err := client.Recommend(ctx, []string{"tensorflow/tensorflow", "BVLC/caffe"}, recsclient.N(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs.Recommendations) != 5 || recs.Recommendations[4].Rank != 5 || recs.Recommendations[0].Repository == "" || recs.ModelVersion == "" {
+		t.Errorf("Wrong recommendations: %+v", recs)
+	}
+
+	results, err := client.RecommendBatch(ctx, []recsclient.BatchRequest{{ID: "1", User: "ml-researcher"}, {Repos: []string{"not/there"}}}, recsclient.N(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ID != "1" || len(results[0].Recommendations) != 3 || results[1].Error == "" {
+		t.Errorf("Wrong batch results: %+v", results)
+	}
+
+	// hoarder has more stars than fit in a page
+	client.Token = "hoarder"
+	stars, err := client.AllStars(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := client.Stars(ctx, 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stars) != page.Total || len(stars) <= 100 {
+		t.Errorf("Wrong stars: %d of %d", len(stars), page.Total)
+	}
+}
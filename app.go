@@ -1,46 +1,140 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/urlfetch"
+	"github.com/jbochi/github-recs/recommender"
 )
 
 const (
-	gitHubAuthenticatedUserURL = "https://api.github.com/user"
-	gitHubStarredURL           = "https://api.github.com/user/starred"
-	gitHubAccessTokenURL       = "https://github.com/login/oauth/access_token"
+	// paths of the GitHub API, under gitHubAPIURL
+	gitHubAuthenticatedUserPath = "/user"
+	gitHubStarredPath           = "/user/starred?per_page=100"
+	gitHubUserRepositoriesPath  = "/user/repos?per_page=100&sort=pushed&affiliation=owner,collaborator,organization_member"
+	// paths of the OAuth flow, under gitHubURL
+	gitHubAccessTokenPath = "/login/oauth/access_token"
+	gitHubAuthorizePath   = "/login/oauth/authorize"
+
+	gitHubUserAgent = "github-recs"
+	// the star media type adds the starred_at timestamps
+	gitHubStarMediaType = "application/vnd.github.v3.star+json"
+
+	publicCacheControl  = "public, max-age=300, s-maxage=3600"
+	privateCacheControl = "private, no-store"
+
+	numRecommendations = 10
+
+	// hedgeDelay is how long to wait for GitHub before duplicating a
+	// request for a page of stars
+	hedgeDelay = 750 * time.Millisecond
 )
 
 var (
+	// where GitHub is; dev mode replaces it with a fake
+	gitHubURL    = "https://github.com"
+	gitHubAPIURL = "https://api.github.com"
+
 	gitHubClientID     = os.Getenv("GITHUB_CLIENT_ID")
 	gitHubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
-	tpl                = map[string]*template.Template{
-		"home": template.Must(template.ParseFiles("templates/base.html", "templates/home.html")),
-		"recs": template.Must(template.ParseFiles("templates/base.html", "templates/recommendations.html")),
+	tplFuncs           = template.FuncMap{
+		"repositoryURL":    recommender.RepositoryURL,
+		"releasesFeedPath": releasesFeedPath,
+		"similarPath":      similarPath,
+		"userPath":         userPath,
+		"theme":            func() theme { return currentTheme },
+	}
+	tpl = map[string]*template.Template{
+		"home":    parseTemplates("templates/base.html", "templates/home.html"),
+		"recs":    parseTemplates("templates/base.html", "templates/recommendations.html", "templates/star_list.html"),
+		"check":   parseTemplates("templates/base.html", "templates/check.html"),
+		"stars":   parseTemplates("templates/base.html", "templates/stars.html", "templates/star_list.html"),
+		"similar": parseTemplates("templates/base.html", "templates/similar.html"),
 	}
-	model *Model
+	// starPreviewLimit is how many stars are listed on the recommendations
+	// page before the "show all" link, set with STAR_PREVIEW_LIMIT
+	starPreviewLimit = 20
+	// starPageLimit is how many pages of 100 stars are fetched from
+	// GitHub at most, set with STAR_PAGE_LIMIT
+	starPageLimit = 10
+	// modelMemoryBudget is the maximum estimated size of the model in
+	// bytes, set with MODEL_MEMORY_BUDGET (e.g. "512MB"). 0 means no limit.
+	modelMemoryBudget int64
+	// modelMapped memory maps the item factors instead of reading them
+	// into the heap, set with MODEL_MMAP
+	modelMapped bool
+	// modelQuantized stores the item factors as int8, set with
+	// MODEL_QUANTIZE
+	modelQuantized bool
 )
 
 type (
 	homeTemplateVars struct {
-		ClientID string
-		Err      string
+		ClientID string `json:"client_id"`
+		LoginURL string `json:"login_url"`
+		Err      string `json:"error,omitempty"`
 	}
 
 	recommendationsTemplateVars struct {
-		User  string
-		Stars []string
-		Recs  []RepositoryScore
+		User      string            `json:"user"`
+		Stars     []string          `json:"stars"`
+		Recs      []RepositoryScore `json:"recommendations"`
+		Summaries map[string]string `json:"summaries,omitempty"`
+		Since     time.Time         `json:"-"`
+		Exclude   string            `json:"exclude,omitempty"`
+		Demo      bool              `json:"demo,omitempty"`
+		// whether they are the recommendations of the public stars of
+		// another user, which anyone can see and share
+		Shared bool `json:"shared,omitempty"`
+		// the languages and topics the recommendations are restricted to
+		Languages string `json:"languages,omitempty"`
+		Topics    string `json:"topics,omitempty"`
+		// the MMR lambda, when the recommendations are diversified
+		Lambda string `json:"lambda,omitempty"`
+		// the stars listed on the page before "show all"
+		StarPreview []string            `json:"-"`
+		Cleanup     []cleanupSuggestion `json:"cleanup,omitempty"`
+		// data added by the registered enrichers, by enricher name and
+		// repository
+		Extras map[string]map[string]interface{} `json:"extras,omitempty"`
+		// the GitHub metadata of the recommendations, for their cards
+		Repositories map[string]*repositoryInfo `json:"-"`
+		// the version of the model that made the recommendations
+		ModelVersion string `json:"model_version,omitempty"`
+		// how many of the stars are on private repositories, which the
+		// model does not know and which are left out
+		PrivateStars int `json:"private_stars,omitempty"`
+		// whether the recommendations are for the repositories the user
+		// owns or contributed to, in Stars, rather than for their stars
+		Contributions bool `json:"contributions,omitempty"`
+		// whether the recommendations are for seed repositories given in
+		// the repos parameter, in Stars, and those of them the model does
+		// not know
+		Seeds   bool     `json:"seeds,omitempty"`
+		Unknown []string `json:"unknown,omitempty"`
+	}
+
+	starsTemplateVars struct {
+		User  string    `json:"user"`
+		Stars []string  `json:"stars"`
+		Since time.Time `json:"-"`
+		// how many of the stars are part of the model
+		InModel int `json:"in_model"`
+	}
+
+	checkTemplateVars struct {
+		User    string                     `json:"user"`
+		Query   string                     `json:"query"`
+		Rank    recommender.RepositoryRank `json:"rank"`
+		Reasons []string                   `json:"reasons"`
 	}
 
 	gitHubAccessTokenResponse struct {
@@ -57,42 +151,137 @@ type (
 	}
 
 	gitHubStarredResponse struct {
-		Repository string `json:"full_name"`
+		StarredAt time.Time        `json:"starred_at"`
+		Repo      gitHubRepository `json:"repo"`
+	}
+
+	gitHubRepository struct {
+		Repository string    `json:"full_name"`
+		Private    bool      `json:"private"`
+		Archived   bool      `json:"archived"`
+		PushedAt   time.Time `json:"pushed_at"`
+		StarredAt  time.Time `json:"-"`
 	}
 )
 
 func init() {
 	var err error
-	model, err = ReadModel("./data/")
-
+	currentTheme, err = loadTheme(os.Getenv)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to create vector model %s", err))
+		panic(err.Error())
 	}
 
-	http.HandleFunc("/", home)
-	http.HandleFunc("/callback", callback)
-}
+	if budget := os.Getenv("MODEL_MEMORY_BUDGET"); budget != "" {
+		modelMemoryBudget, err = recommender.ParseByteSize(budget)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid MODEL_MEMORY_BUDGET: %s", err))
+		}
+	}
 
-func gitHubAuthenticatedRequest(r *http.Request, url string, result interface{}) error {
-	cookie, _ := r.Cookie("token")
-	if cookie == nil {
-		return fmt.Errorf("Unauthorized")
+	if limit := os.Getenv("STAR_PREVIEW_LIMIT"); limit != "" {
+		starPreviewLimit, err = strconv.Atoi(limit)
+		if err != nil || starPreviewLimit <= 0 {
+			panic(fmt.Sprintf("Invalid STAR_PREVIEW_LIMIT: %q", limit))
+		}
 	}
-	ctx := appengine.NewContext(r)
-	client := urlfetch.Client(ctx)
-	gitHubToken := cookie.Value
 
-	fullURL := url + "?access_token=" + gitHubToken
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return err
+	if limit := os.Getenv("STAR_PAGE_LIMIT"); limit != "" {
+		starPageLimit, err = strconv.Atoi(limit)
+		if err != nil || starPageLimit <= 0 || starPageLimit > maxStarPageLimit {
+			panic(fmt.Sprintf("Invalid STAR_PAGE_LIMIT: %q", limit))
+		}
+	}
+
+	if mapped := os.Getenv("MODEL_MMAP"); mapped != "" {
+		modelMapped, err = strconv.ParseBool(mapped)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid MODEL_MMAP: %q", mapped))
+		}
+	}
+
+	if quantized := os.Getenv("MODEL_QUANTIZE"); quantized != "" {
+		modelQuantized, err = strconv.ParseBool(quantized)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid MODEL_QUANTIZE: %q", quantized))
+		}
+	}
+
+	if shards := os.Getenv("MODEL_LOAD_SHARDS"); shards != "" {
+		modelLoadShards, err = strconv.Atoi(shards)
+		if err != nil || modelLoadShards < 0 || modelLoadShards > maxModelLoadShards {
+			panic(fmt.Sprintf("Invalid MODEL_LOAD_SHARDS: %q", shards))
+		}
 	}
-	req.Header.Set("Accept", "application/json")
-	resp, err := client.Do(req)
 
+	if partial := os.Getenv("MODEL_SERVE_PARTIAL"); partial != "" {
+		modelServePartial, err = strconv.ParseBool(partial)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid MODEL_SERVE_PARTIAL: %q", partial))
+		}
+	}
+
+	if versions := os.Getenv("MODEL_VERSIONS"); versions != "" {
+		modelVersions, err = parseModelVersions(versions)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid MODEL_VERSIONS: %s", err))
+		}
+	}
+
+	if sampling := os.Getenv("ACCESS_LOG_SAMPLING"); sampling != "" {
+		accessLogSampling, err = parseAccessLogSampling(sampling)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid ACCESS_LOG_SAMPLING: %s", err))
+		}
+	}
+
+	maintenanceMessage = parseMaintenance(os.Getenv("MAINTENANCE"))
+
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	gitHubAppToken = os.Getenv("GITHUB_TOKEN")
+
+	// the server answers with a warming up page until they are loaded
+	go loadModels()
+
+	handle("/", home)
+	handle("/login", login)
+	handle("/callback", callback)
+	handle("/check", check)
+	handle("/demo", demo)
+	handle("/stars", starsPage)
+	handle("/star", withIdempotency(star))
+	handle("/dismiss", withIdempotency(dismiss))
+	handle("/unstar", withIdempotency(unstar))
+	handle("/export", export)
+	handle("/releases.atom", releasesFeed)
+	handle("/similar/", similar)
+	handle(userPathPrefix, userPage)
+	handle("/api/v1/recommendations", apiRecommendations)
+	handle("/api/v1/recommendations:batch", apiBatchRecommendations)
+	handle("/api/v1/stars", apiStars)
+	handle("/graphql", graphqlEndpoint)
+	handle("/admin/arithmetic", arithmetic)
+	// status is always served, so health checks see the real state
+	http.HandleFunc("/status", status)
+	// and models can be reloaded during maintenance
+	http.HandleFunc("/admin/reload", reloadModel)
+}
+
+// handle registers a handler that is replaced by the maintenance page
+// while maintenance mode is on
+func handle(pattern string, h http.HandlerFunc) {
+	http.HandleFunc(pattern, withAccessLog(pattern, withMaintenance(h)))
+}
+
+func parseTemplates(files ...string) *template.Template {
+	return template.Must(template.New("").Funcs(tplFuncs).Funcs(formatFuncs(defaultLocale)).ParseFiles(files...))
+}
+
+func gitHubAuthenticatedRequest(r *http.Request, url string, result interface{}) error {
+	resp, err := gitHubGet(r, url, "application/json")
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	err = json.NewDecoder(resp.Body).Decode(result)
 	if err != nil {
@@ -102,81 +291,608 @@ func gitHubAuthenticatedRequest(r *http.Request, url string, result interface{})
 	return nil
 }
 
+// gitHubGet issues a GET request to the GitHub API on behalf of the user
+// identified by the token cookie.
+func gitHubGet(r *http.Request, url string, accept string) (*http.Response, error) {
+	return gitHubDo(r, "GET", url, accept)
+}
+
+// gitHubDo issues a request without a body to the GitHub API on behalf of
+// the user identified by the token cookie.
+func gitHubDo(r *http.Request, method string, url string, accept string) (*http.Response, error) {
+	req, err := newGitHubRequest(r, method, url, accept)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+// gitHubHedgedGet is like gitHubGet, but sends a second request if GitHub
+// has not answered the first one after hedgeDelay.
+func gitHubHedgedGet(r *http.Request, url string, accept string) (*http.Response, error) {
+	req, err := newGitHubRequest(r, "GET", url, accept)
+	if err != nil {
+		return nil, err
+	}
+	ctx := r.Context()
+	return hedgedDo(ctx, httpClient, req, hedgeDelay)
+}
+
+// newGitHubRequest creates a request to the GitHub API authenticated with
+// the token cookie. All GitHub API calls go through it, so they share the
+// same headers. The token is sent in the Authorization header, never in
+// the URL, where it would end up in logs.
+func newGitHubRequest(r *http.Request, method string, url string, accept string) (*http.Request, error) {
+	cookie, _ := r.Cookie("token")
+	if cookie == nil {
+		return nil, fmt.Errorf("Unauthorized")
+	}
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(r.Context())
+	req.Header.Set("Authorization", gitHubAuthorization(cookie.Value))
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", gitHubUserAgent)
+	return req, nil
+}
+
+// authenticatedUser returns the login of the signed in user, from the
+// sessions cache if their token was seen recently
 func authenticatedUser(r *http.Request) (string, error) {
+	if login, ok := cachedLogin(r, time.Now()); ok {
+		return login, nil
+	}
 	var result gitHubUserResponse
-	err := gitHubAuthenticatedRequest(r, gitHubAuthenticatedUserURL, &result)
+	err := gitHubAuthenticatedRequest(r, gitHubAPIURL+gitHubAuthenticatedUserPath, &result)
 	if err != nil {
 		return "", err
 	}
 	if result.Error != "" {
 		return "", fmt.Errorf("Error from GitHub: %s", result.Error)
 	}
-
+	if result.User != "" {
+		cacheLogin(r, result.User, time.Now())
+	}
 	return result.User, nil
 }
 
-func starred(r *http.Request) (stars []string, err error) {
-	var result []gitHubStarredResponse
-	err = gitHubAuthenticatedRequest(r, gitHubStarredURL, &result)
+// starred returns the repositories starred by the user since the given
+// time. A zero time returns all of them.
+func starred(r *http.Request, since time.Time) (stars []string, err error) {
+	repos, err := starredRepositories(r)
+	return repositoryNames(starredSince(repos, since)), err
+}
+
+// starredRepositories returns all the repositories starred by the user,
+// with the details GitHub sends about each of them. It follows the pages
+// of stars up to starPageLimit.
+func starredRepositories(r *http.Request) (repos []gitHubRepository, err error) {
+	return starredPages(gitHubAPIURL+gitHubStarredPath, func(url string) (*http.Response, error) {
+		return gitHubHedgedGet(r, url, gitHubStarMediaType)
+	})
+}
+
+// starredPages gets the pages of stars from url on, up to starPageLimit,
+// and returns the repositories in them.
+func starredPages(url string, get func(url string) (*http.Response, error)) (repos []gitHubRepository, err error) {
+	next := url
+	for page := 0; next != "" && page < starPageLimit; page++ {
+		var result []gitHubStarredResponse
+		next, err = starredPage(get, next, &result)
+		if err != nil {
+			return repos, err
+		}
+		for _, r := range result {
+			repo := r.Repo
+			repo.StarredAt = r.StarredAt
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+// publicRepositories leaves out the private repositories, which the
+// model cannot know. GitHub only lists them to tokens with the repo scope.
+func publicRepositories(repos []gitHubRepository) []gitHubRepository {
+	var public []gitHubRepository
+	for _, repo := range repos {
+		if !repo.Private {
+			public = append(public, repo)
+		}
+	}
+	return public
+}
+
+// contributedRepositories returns the public repositories the user owns,
+// collaborates on or can push to through an organization, most recently
+// pushed first, up to a page of them. They seed recommendations for
+// users without public stars.
+func contributedRepositories(r *http.Request) ([]string, error) {
+	var repos []gitHubRepository
+	if err := gitHubAuthenticatedRequest(r, gitHubAPIURL+gitHubUserRepositoriesPath, &repos); err != nil {
+		return nil, err
+	}
+	return repositoryNames(publicRepositories(repos)), nil
+}
+
+// starredPage decodes a page of stars and returns the URL of the next one,
+// or an empty string if it is the last.
+func starredPage(get func(url string) (*http.Response, error), url string, result *[]gitHubStarredResponse) (next string, err error) {
+	resp, err := get(url)
 	if err != nil {
-		return stars, err
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			return "", fmt.Errorf("Unauthorized")
+		case http.StatusNotFound:
+			return "", errUnknownGitHubUser
+		case http.StatusForbidden, http.StatusTooManyRequests:
+			if resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.StatusCode == http.StatusTooManyRequests {
+				return "", errGitHubRateLimited
+			}
+		}
+		return "", fmt.Errorf("Unexpected status from GitHub: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
 	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
 
-	for _, r := range result {
-		stars = append(stars, r.Repository)
+// nextPageURL returns the rel="next" URL of a GitHub Link header, e.g.
+// <https://api.github.com/user/starred?page=2>; rel="next", <...>; rel="last"
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		sections := strings.Split(part, ";")
+		target := strings.TrimSpace(sections[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range sections[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
 	}
+	return ""
+}
 
-	return stars, err
+// starredSince returns the repositories starred at or after since
+func starredSince(repos []gitHubRepository, since time.Time) []gitHubRepository {
+	var result []gitHubRepository
+	for _, repo := range repos {
+		if !repo.StarredAt.Before(since) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+func repositoryNames(repos []gitHubRepository) []string {
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.Repository)
+	}
+	return names
+}
+
+// starsSince parses the since (a date, e.g. 2023-01-01) or window (e.g.
+// 12mo, 2y, 6w or 30d) parameters that restrict which stars are used.
+func starsSince(r *http.Request, now time.Time) (time.Time, error) {
+	if since := r.FormValue("since"); since != "" {
+		t, err := time.Parse("2006-01-02", since)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid since date %q, expected YYYY-MM-DD", since)
+		}
+		return t, nil
+	}
+	window := r.FormValue("window")
+	if window == "" {
+		return time.Time{}, nil
+	}
+	for _, unit := range []struct {
+		suffix              string
+		years, months, days int
+	}{
+		{"mo", 0, 1, 0},
+		{"y", 1, 0, 0},
+		{"w", 0, 0, 7},
+		{"d", 0, 0, 1},
+	} {
+		if !strings.HasSuffix(window, unit.suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(window, unit.suffix))
+		if err != nil || n <= 0 {
+			break
+		}
+		return now.AddDate(-n*unit.years, -n*unit.months, -n*unit.days), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid window %q, expected e.g. 12mo, 2y, 6w or 30d", window)
 }
 
 func home(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.URL.Query()["repos"]; ok {
+		seedsPage(w, r)
+		return
+	}
+
 	var stars []string
-	ctx := appengine.NewContext(r)
+	var starredRepos []gitHubRepository
+
+	since, err := starsSince(r, time.Now())
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	var privateStars int
+	contributions := r.FormValue("from") == "contributions"
 	user, err := authenticatedUser(r)
 	if err == nil {
-		stars, err = starred(r)
+		starredRepos, err = starredRepositories(r)
+		recent := starredSince(starredRepos, since)
+		stars = repositoryNames(publicRepositories(recent))
+		privateStars = len(recent) - len(stars)
+	}
+	if err == nil && contributions {
+		stars, err = contributedRepositories(r)
 	}
 
 	if err != nil {
-		vars := homeTemplateVars{ClientID: gitHubClientID, Err: err.Error()}
+		vars := newHomeTemplateVars(err.Error())
 		if vars.Err == "Unauthorized" {
 			vars.Err = ""
+			if wantsJSON(r) {
+				// a 401 only makes sense to the client that got it
+				w.Header().Set("Cache-Control", privateCacheControl)
+				writeJSON(w, r, http.StatusUnauthorized, vars)
+				return
+			}
+			setPublicCacheHeaders(w, "landing")
+			render(w, r, "home", vars)
+			return
 		}
-		if err = tpl["home"].ExecuteTemplate(w, "base.html", vars); err != nil {
-			log.Errorf(ctx, "%v", err)
-			http.Error(w, "template execution failed", http.StatusInternalServerError)
-		}
+		// the home page shows what failed, in JSON as in HTML
+		w.Header().Set("Cache-Control", privateCacheControl)
+		render(w, r, "home", vars)
 		return
 	}
 
+	w.Header().Set("Cache-Control", privateCacheControl)
 	vars := recommendationsTemplateVars{}
 	vars.User = user
 	vars.Stars = stars
+	vars.StarPreview = previewStars(stars)
+	vars.Since = since
+	vars.Cleanup = cleanupSuggestions(starredRepos, time.Now())
+	vars.PrivateStars = privateStars
+	vars.Contributions = contributions
 
+	model, version := modelForRequest(r)
 	if model == nil {
-		http.Error(w, "model was not initialized", http.StatusInternalServerError)
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
 		return
 	}
+	setModelVersionHeader(w, version)
+	vars.ModelVersion = version
 
-	recs, err := model.Recommend(stars, 10)
+	settings, err := formRecommendSettings(w, r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed: %v", err), http.StatusInternalServerError)
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	vars.Exclude = strings.Join(settings.exclude, ", ")
+	vars.Languages = strings.Join(settings.languages, ", ")
+	vars.Topics = strings.Join(settings.topics, ", ")
+	vars.Lambda = r.FormValue("lambda")
+
+	recs, err := model.Recommend(r.Context(), stars, numRecommendations, settings.options(starredRepos)...)
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusBadRequest))
 		return
 	}
 	vars.Recs = recs
 
-	if err := tpl["recs"].ExecuteTemplate(w, "base.html", vars); err != nil {
-		log.Errorf(ctx, "%v", err)
-		http.Error(w, "template execution failed", http.StatusInternalServerError)
+	fields, err := parseFields(r)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// partial API responses skip the README summaries unless requested
+	if !wantsJSON(r) || fields == nil || hasField(fields, "summary") {
+		vars.Summaries = summarizeRepositories(r, recs)
+	}
+	if !wantsJSON(r) {
+		vars.Repositories = describeRepositories(r, recs)
+	}
+	vars.Extras = enrich(r, recs)
+	if wantsJSON(r) && fields != nil {
+		writeJSON(w, r, http.StatusOK, recommendationsFieldsResponse{user, stars, selectFields(recs, vars.Summaries, fields), version})
+		return
+	}
+
+	render(w, r, "recs", vars)
+}
+
+func check(w http.ResponseWriter, r *http.Request) {
+	var starredRepos []gitHubRepository
+	var since time.Time
+
+	user, err := authenticatedUser(r)
+	if err == nil {
+		starredRepos, err = starredRepositories(r)
+	}
+	if err != nil {
+		loginRequired(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", privateCacheControl)
+	model, version := modelForRequest(r)
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	settings, err := formRecommendSettings(w, r)
+	if err == nil {
+		since, err = starsSince(r, time.Now())
+	}
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vars := checkTemplateVars{User: user, Query: strings.TrimSpace(r.FormValue("repo"))}
+	if vars.Query != "" {
+		stars := repositoryNames(publicRepositories(starredSince(starredRepos, since)))
+		vars.Rank, vars.Reasons, err = whyNot(r.Context(), model, stars, vars.Query, settings.options(starredRepos))
+		if err != nil {
+			httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusInternalServerError))
+			return
+		}
+	}
+
+	render(w, r, "check", vars)
+}
+
+// starsPage lists all the stars used for the recommendations. With
+// ?fragment=1 it renders only the list, which the recommendations page
+// loads when "show all" is clicked.
+func starsPage(w http.ResponseWriter, r *http.Request) {
+	since, err := starsSince(r, time.Now())
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stars []string
+	user, err := authenticatedUser(r)
+	if err == nil {
+		stars, err = starred(r, since)
+	}
+	if err != nil {
+		loginRequired(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", privateCacheControl)
+	vars := starsTemplateVars{User: user, Stars: stars, Since: since}
+	if model, version := modelForRequest(r); model != nil {
+		setModelVersionHeader(w, version)
+		vars.InModel = model.Known(stars)
+	}
+	if r.FormValue("fragment") != "" {
+		renderFragment(w, r, "stars", "starList", vars.Stars)
+		return
+	}
+	render(w, r, "stars", vars)
+}
+
+func previewStars(stars []string) []string {
+	if len(stars) > starPreviewLimit {
+		return stars[:starPreviewLimit]
+	}
+	return stars
+}
+
+// loginRequired sends users that are not signed in back to the landing
+// page, or tells API clients where to sign in.
+func loginRequired(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		w.Header().Set("Cache-Control", privateCacheControl)
+		writeJSON(w, r, http.StatusUnauthorized, newHomeTemplateVars("Unauthorized"))
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func newHomeTemplateVars(err string) homeTemplateVars {
+	return homeTemplateVars{ClientID: gitHubClientID, LoginURL: loginURL(""), Err: err}
+}
+
+// whyNot ranks a repository against the stars of a user with the model
+// that recommends for them, and explains why it is, or is not, among the
+// recommendations shown to them with the given options.
+func whyNot(ctx context.Context, model *recommender.Model, stars []string, repo string, opts []recommender.RecommendOption) (rank recommender.RepositoryRank, reasons []string, err error) {
+	repoID, ok := model.RepositoryID(repo)
+	if !ok {
+		reasons = append(reasons, fmt.Sprintf("The model only knows the %d most starred repositories, and this is not one of them.", model.NumRepositories()))
+		return rank, reasons, nil
+	}
+	for _, star := range stars {
+		if id, ok := model.RepositoryID(star); ok && id == repoID {
+			reasons = append(reasons, "You already starred it, and starred repositories are never recommended.")
+			return rank, reasons, nil
+		}
+	}
+
+	excluded, err := model.Excluded(repo, opts...)
+	if err != nil {
+		return rank, nil, err
+	}
+	if excluded != "" {
+		reasons = append(reasons, fmt.Sprintf("It is left out of your recommendations because %s.", excluded))
+		return rank, reasons, nil
+	}
+
+	rank, err = model.Rank(ctx, stars, repo, opts...)
+	if err != nil {
+		return rank, nil, err
+	}
+	if rank.Rank == 0 {
+		reasons = append(reasons, "It was left out of the ranking.")
+		return rank, reasons, nil
+	}
+	if rank.Rank <= numRecommendations {
+		reasons = append(reasons, fmt.Sprintf("It is ranked #%d, so it is already among your recommendations.", rank.Rank))
+		return rank, reasons, nil
+	}
+	reasons = append(reasons, fmt.Sprintf("It is ranked #%d of %d, and only the top %d are shown.", rank.Rank, rank.Total, numRecommendations))
+
+	recs, err := model.Recommend(ctx, stars, numRecommendations, opts...)
+	if err != nil {
+		return rank, nil, err
+	}
+	if len(recs) > 0 {
+		lowest := recs[len(recs)-1].Score
+		reasons = append(reasons, fmt.Sprintf("Its score (%.2f) is lower than the last recommendation shown (%.2f): it is not similar enough to what you starred.", rank.Score, lowest))
+	}
+	return rank, reasons, nil
+}
+
+// formMMR returns the MMR option for the lambda parameter, e.g.
+// lambda=0.7 to diversify the recommendations a little.
+// recommendSettings are what a user chose to see among their
+// recommendations: the exclude patterns saved in their cookie, and the
+// lang, topic and lambda parameters.
+type recommendSettings struct {
+	exclude, languages, topics []string
+	diversify                  recommender.RecommendOption
+}
+
+func formRecommendSettings(w http.ResponseWriter, r *http.Request) (s recommendSettings, err error) {
+	if s.exclude, err = excludeSetting(w, r); err != nil {
+		return s, err
+	}
+	if s.languages, err = formList(r, "lang", maxPatterns); err != nil {
+		return s, err
+	}
+	if s.topics, err = formList(r, "topic", maxPatterns); err != nil {
+		return s, err
+	}
+	s.diversify, err = formMMR(r)
+	return s, err
+}
+
+// options are the options of the recommendations page. Stars given before
+// the window are not used, but still never recommended.
+func (s recommendSettings) options(starredRepos []gitHubRepository) []recommender.RecommendOption {
+	return []recommender.RecommendOption{
+		recommender.ExcludePatterns(s.exclude...),
+		recommender.ExcludeRepositories(repositoryNames(starredRepos)...),
+		recommender.Languages(s.languages...),
+		recommender.Topics(s.topics...),
+		s.diversify,
+		recommender.Filters(filters...),
+	}
+}
+
+func formMMR(r *http.Request) (recommender.RecommendOption, error) {
+	value := r.FormValue("lambda")
+	if value == "" {
+		return recommender.MMR(1), nil
+	}
+	lambda, err := strconv.ParseFloat(value, 64)
+	if err != nil || lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("Invalid lambda %q, expected a number from 0 to 1", value)
+	}
+	return recommender.MMR(lambda), nil
+}
+
+// recommendStatus is the status of a response to a failed Recommend: 503
+// if the request was cancelled or ran out of time, status otherwise.
+func recommendStatus(err error, status int) int {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return http.StatusServiceUnavailable
+	}
+	return status
+}
+
+// excludeSetting returns the owners and patterns the user never wants to
+// see recommended. They are saved in a cookie when given in the exclude
+// parameter, so they apply to later visits too, unless there are more than
+// maxPatterns of them.
+func excludeSetting(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	if values, ok := r.URL.Query()["exclude"]; ok {
+		patterns, err := checkList("exclude patterns", splitPatterns(strings.Join(values, ",")), maxPatterns)
+		if err != nil {
+			return nil, err
+		}
+		setExcludeCookie(w, patterns)
+		return patterns, nil
+	}
+	return excludeCookie(r), nil
+}
+
+func excludeCookie(r *http.Request) []string {
+	cookie, _ := r.Cookie("exclude")
+	if cookie == nil {
+		return nil
+	}
+	value, _ := url.QueryUnescape(cookie.Value)
+	return splitPatterns(value)
+}
+
+func setExcludeCookie(w http.ResponseWriter, patterns []string) {
+	cookie := http.Cookie{Name: "exclude", Value: url.QueryEscape(strings.Join(patterns, ",")), Path: "/", MaxAge: 365 * 24 * 60 * 60}
+	if len(patterns) == 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, &cookie)
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// setPublicCacheHeaders marks a non-personalized response as cacheable by
+// browsers and shared caches. The response still varies on the token
+// cookie, since the same URL renders recommendations for signed in users,
+// on the Accept header used for content negotiation, and on the
+// Accept-Language header numbers are formatted for.
+func setPublicCacheHeaders(w http.ResponseWriter, surrogateKeys ...string) {
+	w.Header().Set("Cache-Control", publicCacheControl)
+	w.Header().Set("Vary", "Cookie, Accept, Accept-Language, "+modelVersionHeader)
+	if len(surrogateKeys) > 0 {
+		w.Header().Set("Surrogate-Key", strings.Join(surrogateKeys, " "))
 	}
 }
 
 func callback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	if !checkOAuthState(w, r) {
+		renderError(w, r, http.StatusForbidden, "Sorry, that sign in link has expired or was not started here. Please try again.")
+		return
+	}
+
 	// create request to get token
 	sessionCode := r.FormValue("code")
-	ctx := appengine.NewContext(r)
-	client := urlfetch.Client(ctx)
 	values := url.Values{
 		"client_id":     []string{gitHubClientID},
 		"client_secret": []string{gitHubClientSecret},
@@ -184,19 +900,20 @@ func callback(w http.ResponseWriter, r *http.Request) {
 	}
 	body := values.Encode()
 
-	req, err := http.NewRequest("POST", gitHubAccessTokenURL, strings.NewReader(body))
+	req, err := http.NewRequest("POST", gitHubURL+gitHubAccessTokenPath, strings.NewReader(body))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	req = req.WithContext(r.Context())
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", gitHubUserAgent)
 
 	// issue request
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		fmt.Fprintf(w, "Something went wrong! %v", err)
+		httpError(w, r, fmt.Sprintf("Something went wrong! %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -204,12 +921,12 @@ func callback(w http.ResponseWriter, r *http.Request) {
 	var result gitHubAccessTokenResponse
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if result.Error != "" {
-		http.Error(w, result.Error, http.StatusInternalServerError)
+		httpError(w, r, result.Error, http.StatusInternalServerError)
 		return
 	}
 
@@ -1,45 +1,115 @@
 package server
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestModel(t *testing.T) {
-	model, err := ReadModel("./data/")
-	if err != nil {
-		t.Fatalf("Unable to read model: %v", err)
+func TestMain(m *testing.M) {
+	// the model is loaded in the background
+	<-modelsLoaded
+	os.Exit(m.Run())
+}
+
+func TestRecommendationsTemplateStarPreview(t *testing.T) {
+	var stars []string
+	for i := 0; i < starPreviewLimit+5; i++ {
+		stars = append(stars, fmt.Sprintf("owner/repo%d", i))
 	}
-	if model == nil {
-		t.Fatalf("Did not return a model")
+	vars := recommendationsTemplateVars{User: "user", Stars: stars, StarPreview: previewStars(stars)}
+	var buf bytes.Buffer
+	if err := tpl["recs"].ExecuteTemplate(&buf, "base.html", vars); err != nil {
+		t.Fatalf("Failed to render: %v", err)
 	}
-	recs, err := model.Recommend([]string{"tensorflow/tensorflow", "BVLC/caffe"}, 10)
-	if err != nil {
-		t.Errorf("Failed to recommend: %s", err)
+	page := buf.String()
+	if strings.Contains(page, stars[starPreviewLimit]) {
+		t.Errorf("Stars beyond the preview limit were listed")
 	}
-	if len(recs) != 10 {
-		t.Errorf("Wrong number of recommendations: %v", recs)
+	if !strings.Contains(page, fmt.Sprintf("Show all %d stars", len(stars))) {
+		t.Errorf("Missing show all link")
 	}
 }
 
-func BenchmarkModel(b *testing.B) {
-	model, err := ReadModel("./data/")
-	if err != nil {
-		b.Fatalf("Unable to read model: %v", err)
+func TestStarsSince(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		want  time.Time
+		err   bool
+	}{
+		{"", time.Time{}, false},
+		{"since=2023-01-01", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"window=12mo", now.AddDate(0, -12, 0), false},
+		{"window=2y", now.AddDate(-2, 0, 0), false},
+		{"window=6w", now.AddDate(0, 0, -42), false},
+		{"window=30d", now.AddDate(0, 0, -30), false},
+		{"since=yesterday", time.Time{}, true},
+		{"window=12", time.Time{}, true},
+		{"window=-1mo", time.Time{}, true},
 	}
-	if model == nil {
-		b.Fatalf("Did not return a model")
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+		got, err := starsSince(r, now)
+		if (err != nil) != tt.err {
+			t.Errorf("starsSince(%q) error = %v", tt.query, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("starsSince(%q) = %v, want %v", tt.query, got, tt.want)
+		}
 	}
-	var recs []RepositoryScore
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		recs, err = model.Recommend([]string{"tensorflow/tensorflow", "BVLC/caffe"}, 10)
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{`<https://api.github.com/user/starred?page=2>; rel="next", <https://api.github.com/user/starred?page=5>; rel="last"`, "https://api.github.com/user/starred?page=2"},
+		{`<https://api.github.com/user/starred?page=1>; rel="first", <https://api.github.com/user/starred?page=4>; rel="prev"`, ""},
+		{`<https://api.github.com/user/starred?page=3&per_page=100>; rel="next"`, "https://api.github.com/user/starred?page=3&per_page=100"},
+	}
+	for _, tt := range tests {
+		if got := nextPageURL(tt.link); got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
 	}
+}
+
+func FuzzNextPageURL(f *testing.F) {
+	f.Add(`<https://api.github.com/user/starred?page=2>; rel="next", <https://api.github.com/user/starred?page=5>; rel="last"`)
+	f.Add(`<>; rel="next"`)
+	f.Add(`<;>, rel="next";`)
+	f.Fuzz(func(t *testing.T, link string) {
+		if got := nextPageURL(link); got != "" && !strings.Contains(link, "<"+got+">") {
+			t.Errorf("nextPageURL(%q) = %q, which is not a target of the header", link, got)
+		}
+	})
+}
 
+func TestNewGitHubRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := newGitHubRequest(r, "GET", gitHubAPIURL+gitHubStarredPath, "application/json"); err == nil {
+		t.Errorf("Expected error without a token")
+	}
+	r.AddCookie(&http.Cookie{Name: "token", Value: "secret"})
+	req, err := newGitHubRequest(r, "GET", gitHubAPIURL+gitHubStarredPath, "application/json")
 	if err != nil {
-		b.Errorf("Failed to recommend: %s", err)
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if strings.Contains(req.URL.String(), "secret") {
+		t.Errorf("Token leaked in the URL: %s", req.URL)
+	}
+	if got := req.Header.Get("Authorization"); got != "token secret" {
+		t.Errorf("Wrong Authorization header: %q", got)
 	}
-	if len(recs) != 10 {
-		b.Errorf("Wrong number of recommendations: %v", recs)
+	if req.Header.Get("User-Agent") == "" || req.Header.Get("Accept") != "application/json" {
+		t.Errorf("Missing headers: %v", req.Header)
 	}
 }
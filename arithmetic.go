@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// arithmeticResponse is the JSON response of /admin/arithmetic
+type arithmeticResponse struct {
+	Query        string             `json:"query"`
+	Terms        []recommender.Term `json:"terms"`
+	Results      []RepositoryScore  `json:"results"`
+	ModelVersion string             `json:"model_version,omitempty"`
+}
+
+// arithmetic lists the repositories closest to the sum and difference of
+// the factors of the repositories of the q parameter, as in
+// /admin/arithmetic?q=kubernetes/kubernetes+-+golang/go+%2B+rust-lang/rust,
+// to explore what the factors capture. It takes the n, lang and topic
+// parameters of the API, and the admin token.
+func arithmetic(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	if !checkAdmin(w, r) {
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		apiError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	model, version := modelForRequest(r)
+	if model == nil {
+		apiError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	n, err := positiveFormInt(r, "n", numRecommendations, maxAPIRecommendations)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := formLabels(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.FormValue("q")
+	terms, err := recommender.ParseArithmetic(query)
+	if err == nil && len(terms) > maxSeeds {
+		err = fmt.Errorf("Too many terms: %d, expected at most %d", len(terms), maxSeeds)
+	}
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := model.Arithmetic(terms, n, append(opts, recommender.Filters(filters...))...)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if results == nil {
+		results = []RepositoryScore{}
+	}
+	writeJSON(w, r, http.StatusOK, arithmeticResponse{query, terms, results, version})
+}
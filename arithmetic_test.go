@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestArithmetic(t *testing.T) {
+	defer func(saved string) { adminToken = saved }(adminToken)
+	adminToken = "secret"
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/admin/arithmetic?"+url.Values{"q": {query}}.Encode(), nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		arithmetic(w, r)
+		return w
+	}
+
+	if w := get("golang/go", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status without the admin token: %d", w.Code)
+	}
+	for _, query := range []string{"", "golang/go rust-lang/rust", "golang/go - not/there"} {
+		if w := get(query, "secret"); w.Code != http.StatusBadRequest {
+			t.Errorf("Wrong status of %q: %d %s", query, w.Code, w.Body.String())
+		}
+	}
+
+	w := get("kubernetes/kubernetes - golang/go + rust-lang/rust", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed: %d %s", w.Code, w.Body.String())
+	}
+	var response arithmeticResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Terms) != 3 || response.Terms[1].Weight != -1 || len(response.Results) != numRecommendations {
+		t.Errorf("Wrong response: %+v", response)
+	}
+}
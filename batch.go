@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	// maxBatchRequests caps the requests of a batch
+	maxBatchRequests = 100
+	// batchWorkers is how many requests of a batch are served at once
+	batchWorkers = 8
+	// maxBatchBody caps the size of a batch request body
+	maxBatchBody = 1 << 20
+)
+
+type (
+	apiBatchRequest struct {
+		Requests []apiBatchItem `json:"requests"`
+	}
+
+	// apiBatchItem asks for the recommendations of a GitHub user, from
+	// their public stars, or of seed repositories
+	apiBatchItem struct {
+		// echoed back, to match results with requests
+		ID    string   `json:"id,omitempty"`
+		User  string   `json:"user,omitempty"`
+		Repos []string `json:"repos,omitempty"`
+	}
+
+	apiBatchResult struct {
+		ID              string              `json:"id,omitempty"`
+		User            string              `json:"user,omitempty"`
+		Items           []string            `json:"items"`
+		Recommendations []apiRecommendation `json:"recommendations"`
+		// the seed repositories the model does not know, left out
+		Unknown []string `json:"unknown,omitempty"`
+		// why there are no recommendations for this request; the others
+		// are served anyway
+		Error string `json:"error,omitempty"`
+	}
+
+	apiBatchResponse struct {
+		Results      []apiBatchResult `json:"results"`
+		ModelVersion string           `json:"model_version"`
+	}
+)
+
+// apiBatchRecommendations serves up to maxBatchRequests recommendation
+// requests in one call, batchWorkers at a time, e.g. to precompute the
+// recommendations of a team. The body is a JSON object with a list of
+// requests, each for the public stars of a user or for seed repositories:
+//
+//	{"requests": [{"id": "1", "user": "octocat"}, {"repos": ["golang/go"]}]}
+//
+// The n, lang, topic, lambda and formatted parameters of
+// apiRecommendations apply to all of them. Results are in the order of the
+// requests, and a failed request has an error instead of failing the batch.
+func apiBatchRecommendations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		apiError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	model, version := modelForRequest(r)
+	if model == nil {
+		apiError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	var batch apiBatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&batch); err != nil {
+		apiError(w, r, fmt.Sprintf("Invalid batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Requests) == 0 || len(batch.Requests) > maxBatchRequests {
+		apiError(w, r, fmt.Sprintf("Invalid batch of %d requests, expected 1 to %d", len(batch.Requests), maxBatchRequests), http.StatusBadRequest)
+		return
+	}
+	for i, item := range batch.Requests {
+		// as the repos parameter of apiRecommendations
+		repos, err := checkList("repos", splitPatterns(strings.Join(item.Repos, ",")), maxSeeds)
+		if err != nil {
+			apiError(w, r, fmt.Sprintf("Request %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		item.Repos = repos
+		batch.Requests[i] = item
+		if (item.User == "") == (len(item.Repos) == 0) {
+			apiError(w, r, fmt.Sprintf("Request %d must have either a user or repos", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	n, err := positiveFormInt(r, "n", numRecommendations, maxAPIRecommendations)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	diversify, err := formMMR(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := formLabels(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts = append(opts, diversify, recommender.Filters(filters...))
+	locale, err := formattedLocale(r)
+	if err != nil {
+		apiError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	results := make([]apiBatchResult, len(batch.Requests))
+	requests := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < batchWorkers && i < len(batch.Requests); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range requests {
+				item := batch.Requests[i]
+				result := apiBatchResult{ID: item.ID, User: item.User, Items: []string{}, Recommendations: []apiRecommendation{}}
+				if item.Repos != nil {
+					result.Items, result.Unknown = knownRepositories(model, item.Repos)
+					if len(result.Items) == 0 {
+						result.Items = []string{}
+						result.Error = unknownRepositoriesError(result.Unknown).Error()
+						results[i] = result
+						continue
+					}
+				}
+				if item.User != "" {
+					repos, err := publicStarredRepositories(ctx, item.User)
+					if err != nil {
+						result.Error = err.Error()
+						results[i] = result
+						continue
+					}
+					result.Items = append(result.Items, repositoryNames(repos)...)
+				}
+				recs, err := model.Recommend(ctx, result.Items, n, opts...)
+				if err != nil {
+					result.Error = err.Error()
+				}
+				for rank, rec := range recs {
+					result.Recommendations = append(result.Recommendations, newAPIRecommendation(rec, rank+1, locale))
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range batch.Requests {
+		requests <- i
+	}
+	close(requests)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		apiError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusInternalServerError))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, apiBatchResponse{results, version})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIBatchRecommendations(t *testing.T) {
+	server, _, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", server.URL+"/api/v1/recommendations:batch?n=3", strings.NewReader(body))
+		apiBatchRecommendations(w, r)
+		return w
+	}
+	w := post(`{"requests": [
+		{"id": "ml", "user": "ml-researcher"},
+		{"repos": ["golang/go", " BVLC/caffe "]},
+		{"user": "private-eye"},
+		{"user": "nobody-at-all"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status: %d %s", w.Code, w.Body.String())
+	}
+	var response apiBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(response.Results) != 4 || response.ModelVersion != defaultModelVersion {
+		t.Fatalf("Wrong response: %+v", response)
+	}
+	ml, seeds, private, unknown := response.Results[0], response.Results[1], response.Results[2], response.Results[3]
+	if ml.ID != "ml" || len(ml.Items) != 5 || len(ml.Recommendations) != 3 || ml.Error != "" {
+		t.Errorf("Wrong result for a user: %+v", ml)
+	}
+	if len(seeds.Items) != 2 || seeds.Items[1] != "BVLC/caffe" || len(seeds.Recommendations) != 3 {
+		t.Errorf("Wrong result for seeds: %+v", seeds)
+	}
+	if len(private.Items) != 0 || private.Error != "" {
+		t.Errorf("Private stars were used: %+v", private)
+	}
+	if unknown.Error == "" || len(unknown.Recommendations) != 0 {
+		t.Errorf("Unknown user did not fail: %+v", unknown)
+	}
+
+	w = httptest.NewRecorder()
+	apiBatchRecommendations(w, httptest.NewRequest("POST", "/api/v1/recommendations:batch?n=1&formatted=true&locale=de", strings.NewReader(`{"requests": [{"repos": ["golang/go"]}]}`)))
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || !strings.Contains(response.Results[0].Recommendations[0].Formatted["score"], ",") {
+		t.Errorf("Wrong formatted result: %s", w.Body)
+	}
+
+	tooMany := bytes.NewBufferString(`{"requests": [`)
+	for i := 0; i <= maxBatchRequests; i++ {
+		if i > 0 {
+			tooMany.WriteString(",")
+		}
+		tooMany.WriteString(`{"repos": ["golang/go"]}`)
+	}
+	tooMany.WriteString("]}")
+	for _, body := range []string{"", "{", `{"requests": []}`, `{"requests": [{}]}`, `{"requests": [{"user": "a", "repos": ["b/c"]}]}`, tooMany.String()} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("Wrong status for %.40q: %d", body, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	apiBatchRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations:batch", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Wrong status for a GET: %d", w.Code)
+	}
+}
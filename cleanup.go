@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// staleAfter is how long a starred repository can go without pushes
+// before it is suggested for unstarring
+const staleAfter = 2 * 365 * 24 * time.Hour
+
+type (
+	cleanupSuggestion struct {
+		Repository string `json:"repository"`
+		Reason     string `json:"reason"`
+	}
+
+	// unstarResponse lists the repositories unstarred, even when the token
+	// turned out to lack the scope to unstar the rest
+	unstarResponse struct {
+		Unstarred []string `json:"unstarred"`
+		Failed    []string `json:"failed,omitempty"`
+		Error     string   `json:"error,omitempty"`
+		LoginURL  string   `json:"login_url,omitempty"`
+	}
+)
+
+// cleanupSuggestions lists the starred repositories that are archived or
+// have not been pushed to for a long time.
+func cleanupSuggestions(repos []gitHubRepository, now time.Time) []cleanupSuggestion {
+	var suggestions []cleanupSuggestion
+	for _, repo := range repos {
+		switch {
+		case repo.Archived:
+			suggestions = append(suggestions, cleanupSuggestion{repo.Repository, "archived"})
+		case !repo.PushedAt.IsZero() && now.Sub(repo.PushedAt) > staleAfter:
+			reason := fmt.Sprintf("no pushes since %s", repo.PushedAt.Format("Jan 2006"))
+			suggestions = append(suggestions, cleanupSuggestion{repo.Repository, reason})
+		}
+	}
+	return suggestions
+}
+
+// unstar removes the stars of all the repositories in the repo parameters,
+// up to maxSeeds
+func unstar(w http.ResponseWriter, r *http.Request) {
+	if !checkScriptPost(w, r) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	refs, err := checkList("repo", r.PostForm["repo"], maxSeeds)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result unstarResponse
+	for _, ref := range refs {
+		repo := recommender.NormalizeRepository(ref)
+		if repo == "" {
+			result.Failed = append(result.Failed, ref)
+			continue
+		}
+		resp, err := gitHubDo(r, "DELETE", gitHubAPIURL+fmt.Sprintf(gitHubStarPath, repo), "application/json")
+		if err != nil {
+			result.Failed = append(result.Failed, repo)
+			continue
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			result.Unstarred = append(result.Unstarred, repo)
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+			scope := starScopeError()
+			result.Error, result.LoginURL = scope.Error, scope.LoginURL
+			writeJSON(w, r, http.StatusForbidden, result)
+			return
+		default:
+			result.Failed = append(result.Failed, repo)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanupSuggestions(t *testing.T) {
+	now := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+	repos := []gitHubRepository{
+		{Repository: "active/repo", PushedAt: now.AddDate(0, -1, 0)},
+		{Repository: "archived/repo", Archived: true, PushedAt: now.AddDate(0, -1, 0)},
+		{Repository: "stale/repo", PushedAt: time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Repository: "unknown/repo"},
+	}
+	want := []cleanupSuggestion{
+		{"archived/repo", "archived"},
+		{"stale/repo", "no pushes since Mar 2014"},
+	}
+	if got := cleanupSuggestions(repos, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("cleanupSuggestions = %v, want %v", got, want)
+	}
+}
+
+func TestUnstar(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/starred/golang/go" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// GitHub answers 404 when the token lacks the scope
+		http.NotFound(w, r)
+	}))
+	defer github.Close()
+	defer func(url string) { gitHubAPIURL = url }(gitHubAPIURL)
+	gitHubAPIURL = github.URL
+
+	post := func(repos []string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/unstar", strings.NewReader(url.Values{"repo": repos}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Requested-With", "fetch")
+		r.AddCookie(&http.Cookie{Name: "token", Value: "secret"})
+		w := httptest.NewRecorder()
+		unstar(w, r)
+		return w
+	}
+
+	w := post([]string{"golang/go", "BVLC/caffe"})
+	var result unstarResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if w.Code != http.StatusForbidden || result.Error == "" || !reflect.DeepEqual(result.Unstarred, []string{"golang/go"}) {
+		t.Errorf("Wrong response without the scope: %d %s", w.Code, w.Body)
+	}
+
+	if w := post(make([]string, maxSeeds+1)); w.Code != http.StatusBadRequest {
+		t.Errorf("Wrong status for too many repos: %d", w.Code)
+	}
+}
@@ -0,0 +1,25 @@
+// Command convert-model writes the binary model file of a model directory
+// from its item_factors.npy and items.csv, which the server then loads
+// instead of them. Run it again whenever the model changes.
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+func main() {
+	data := flag.String("data", "./data/", "the model directory")
+	flag.Parse()
+
+	path := *data
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	if err := recommender.ConvertModel(path); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+// Command github-recs runs the recommendations server. Run it from the
+// root of the repository, where the data and templates directories are.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	// registers the handlers on http.DefaultServeMux
+	server "github.com/jbochi/github-recs"
+)
+
+func main() {
+	dev := flag.Bool("dev", false, "sign in to a fake GitHub with seeded users, without credentials")
+	token := flag.String("token", "", "sign in with this GitHub personal access token, fine-grained or classic, instead of OAuth, to run locally")
+	flag.Parse()
+
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	host := ""
+	if *dev {
+		server.EnableDevMode("http://localhost:" + port)
+		log.Printf("Dev mode: sign in at http://localhost:%s/login", port)
+	} else if *token != "" {
+		login, warnings, err := server.UseLocalToken(*token)
+		if err != nil {
+			log.Fatalf("Unable to use the token: %v", err)
+		}
+		for _, warning := range warnings {
+			log.Print(warning)
+		}
+		log.Printf("Signing in as %s: open http://localhost:%s/login", login, port)
+		// anyone who can reach the server is signed in with the token
+		host = "127.0.0.1"
+	}
+	log.Printf("Listening on %s:%s", host, port)
+	log.Fatal(http.ListenAndServe(host+":"+port, nil))
+}
@@ -0,0 +1,188 @@
+// Command recs prints the recommendations for seed repositories, or for
+// the public stars of a GitHub user, from a model directory or from the
+// API of a running server:
+//
+//	recs tensorflow/tensorflow BVLC/caffe
+//	recs -user octocat -n 20 -format json
+//	recs -api https://example.com golang/go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jbochi/github-recs/github"
+	"github.com/jbochi/github-recs/recommender"
+	"github.com/jbochi/github-recs/recsclient"
+)
+
+// requestTimeout bounds the requests to GitHub and to the API
+const requestTimeout = 30 * time.Second
+
+type (
+	recommendation struct {
+		recommender.RepositoryScore
+		Rank int `json:"rank"`
+	}
+
+	// result has the fields of the API response the command prints, and
+	// is printed as is in JSON
+	result struct {
+		User            string           `json:"user,omitempty"`
+		Items           []string         `json:"items"`
+		Unknown         []string         `json:"unknown,omitempty"`
+		Recommendations []recommendation `json:"recommendations"`
+	}
+)
+
+func main() {
+	data := flag.String("model", "./data/", "the model directory")
+	api := flag.String("api", "", "the URL of a server to get the recommendations from, instead of a model directory")
+	user := flag.String("user", "", "recommend for the public stars of this GitHub user instead of seed repositories")
+	n := flag.Int("n", 10, "the number of recommendations")
+	format := flag.String("format", "table", "the output format: table or json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [owner/repo ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+
+	var seeds []string
+	for _, arg := range flag.Args() {
+		for _, repo := range strings.Split(arg, ",") {
+			if repo = strings.TrimSpace(repo); repo != "" {
+				seeds = append(seeds, repo)
+			}
+		}
+	}
+	if (*user == "") == (len(seeds) == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *n <= 0 {
+		log.Fatalf("Invalid -n %d, expected a positive number", *n)
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatalf("Invalid -format %q, expected table or json", *format)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var res result
+	var err error
+	if *api != "" {
+		res, err = remoteRecommendations(ctx, strings.TrimSuffix(*api, "/"), *user, seeds, *n)
+	} else {
+		res, err = localRecommendations(ctx, *data, *user, seeds, *n)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(res); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(res.Unknown) > 0 {
+		log.Printf("Left out the repos the model does not know: %s", strings.Join(res.Unknown, ", "))
+	}
+	if err := printTable(os.Stdout, res.Recommendations); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// localRecommendations reads the model in path and recommends with it
+func localRecommendations(ctx context.Context, path, user string, seeds []string, n int) (result, error) {
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	model, err := recommender.ReadModel(path)
+	if err != nil {
+		return result{}, fmt.Errorf("Unable to read the model: %v", err)
+	}
+	res := result{User: user, Items: []string{}, Recommendations: []recommendation{}}
+	if user != "" {
+		// stars the model does not know are common, and not listed
+		res.Items, err = github.New(os.Getenv("GITHUB_TOKEN")).PublicStars(ctx, user)
+		if err != nil {
+			return result{}, fmt.Errorf("Unable to get the stars of %s: %v", user, err)
+		}
+	}
+	for _, repo := range seeds {
+		if model.Repository(repo) == "" {
+			res.Unknown = append(res.Unknown, repo)
+		} else {
+			res.Items = append(res.Items, repo)
+		}
+	}
+	if len(res.Items) == 0 {
+		return result{}, fmt.Errorf("Unable to recommend: none of the repos are known to the model")
+	}
+	recs, err := model.Recommend(ctx, res.Items, n)
+	if err != nil {
+		return result{}, err
+	}
+	for i, rec := range recs {
+		res.Recommendations = append(res.Recommendations, recommendation{rec, i + 1})
+	}
+	return res, nil
+}
+
+// remoteRecommendations gets the recommendations from the API of the
+// server at baseURL, the batch one for the stars of a user, which is the
+// one that takes them
+func remoteRecommendations(ctx context.Context, baseURL, user string, seeds []string, n int) (result, error) {
+	client := recsclient.New(baseURL)
+	var res result
+	if user == "" {
+		recs, err := client.Recommend(ctx, seeds, recsclient.N(n))
+		if err != nil {
+			return result{}, err
+		}
+		res = result{Items: recs.Items, Unknown: recs.Unknown, Recommendations: []recommendation{}}
+		for _, rec := range recs.Recommendations {
+			res.Recommendations = append(res.Recommendations, newRecommendation(rec))
+		}
+		return res, nil
+	}
+
+	results, err := client.RecommendBatch(ctx, []recsclient.BatchRequest{{User: user}}, recsclient.N(n))
+	if err != nil {
+		return result{}, err
+	}
+	if results[0].Error != "" {
+		return result{}, fmt.Errorf("Unable to recommend for %s: %s", user, results[0].Error)
+	}
+	res = result{User: user, Items: results[0].Items, Recommendations: []recommendation{}}
+	for _, rec := range results[0].Recommendations {
+		res.Recommendations = append(res.Recommendations, newRecommendation(rec))
+	}
+	return res, nil
+}
+
+func newRecommendation(rec recsclient.Recommendation) recommendation {
+	return recommendation{recommender.RepositoryScore{ID: rec.ID, Repository: rec.Repository, Score: rec.Score, Because: rec.Because}, rec.Rank}
+}
+
+// printTable prints the recommendations in aligned columns
+func printTable(w io.Writer, recs []recommendation) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tREPOSITORY\tSCORE\tBECAUSE")
+	for _, rec := range recs {
+		fmt.Fprintf(tw, "%d\t%s\t%.3f\t%s\n", rec.Rank, rec.Repository, rec.Score, strings.Join(rec.Because, ", "))
+	}
+	return tw.Flush()
+}
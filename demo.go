@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// demoStars is the star profile of a synthetic, typical web developer. It
+// lets visitors see recommendations without signing in with GitHub.
+var demoStars = []string{
+	"facebook/react",
+	"reactjs/redux",
+	"vuejs/vue",
+	"twbs/bootstrap",
+	"webpack/webpack",
+	"babel/babel",
+	"expressjs/express",
+	"nodejs/node",
+	"Microsoft/TypeScript",
+	"Microsoft/vscode",
+	"lodash/lodash",
+	"moment/moment",
+	"airbnb/javascript",
+	"prettier/prettier",
+}
+
+func demo(w http.ResponseWriter, r *http.Request) {
+	model := currentModel()
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	recs, err := model.Recommend(r.Context(), demoStars, numRecommendations, recommender.Filters(filters...))
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	setPublicCacheHeaders(w, "demo")
+	vars := recommendationsTemplateVars{
+		User:        "a typical web developer",
+		Stars:       demoStars,
+		StarPreview: demoStars,
+		Recs:        recs,
+		Demo:        true,
+	}
+	render(w, r, "recs", vars)
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+func TestDemoStarsAreInModel(t *testing.T) {
+	model, err := recommender.ReadModel("./data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	for _, repo := range demoStars {
+		if _, ok := model.RepositoryID(repo); !ok {
+			t.Errorf("Demo star %s is not in the model", repo)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// devGitHubPrefix is where dev mode serves the fake GitHub
+	devGitHubPrefix = "/dev/github"
+	devTokenPrefix  = "dev-"
+	devPerPage      = 30
+	// devHoarderStars is how many stars the hoarder has, more than fit
+	// in a page, if the model has that many repositories
+	devHoarderStars = 250
+)
+
+type (
+	// fakeGitHub implements the parts of GitHub the server uses: the OAuth
+	// flow, the user, their stars, repositories, READMEs and releases
+	// feeds. Its users sign in without a password, and their token is
+	// their login.
+	fakeGitHub struct {
+		sync.Mutex
+		// stars of each user, newest first
+		stars map[string][]gitHubStarredResponse
+		// users whose token was revoked, until they sign in again
+		revoked map[string]bool
+		// down makes the API fail with 500s, as in an outage
+		down bool
+		// seedHoarder adds the hoarder once the model is loaded, which
+		// happens after dev mode starts
+		seedHoarder bool
+	}
+)
+
+var fakeAuthorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<title>Fake GitHub</title>
+<h1>Sign in to the fake GitHub as:</h1>
+<ul>
+{{ range . }}<li><a href="{{ .URL }}">{{ .Login }}</a> ({{ .Stars }} stars)</li>
+{{ end }}</ul>
+`))
+
+// devContributions are the public repositories each fake user owns or
+// contributed to, besides private ones of their own
+var devContributions = map[string][]string{
+	"private-eye": {"tensorflow/tensorflow", "fchollet/keras"},
+}
+
+// EnableDevMode points the server at a fake GitHub with seeded users,
+// served under /dev/github of baseURL, e.g. http://localhost:8080, so
+// contributors can run the whole product locally without credentials.
+func EnableDevMode(baseURL string) {
+	gitHubURL = strings.TrimSuffix(baseURL, "/") + devGitHubPrefix
+	gitHubAPIURL = gitHubURL + "/api"
+	if gitHubClientID == "" {
+		gitHubClientID = "dev"
+	}
+	http.Handle(devGitHubPrefix+"/", http.StripPrefix(devGitHubPrefix, newDevGitHub(time.Now())))
+}
+
+// devUsers seeds the fake GitHub: a typical web developer, a machine
+// learning researcher, a new user without stars and a user who only
+// starred private repositories. The hoarder comes with the model.
+func devUsers(now time.Time) map[string][]gitHubStarredResponse {
+	users := map[string][]string{
+		"webdev":        demoStars,
+		"ml-researcher": {"tensorflow/tensorflow", "BVLC/caffe", "fchollet/keras", "scikit-learn/scikit-learn", "pytorch/pytorch"},
+		"newbie":        nil,
+		"private-eye":   {"private-eye/dossiers", "private-eye/cases"},
+	}
+	stars := map[string][]gitHubStarredResponse{}
+	for login, repos := range users {
+		stars[login] = devStars(login, repos, now)
+	}
+	return stars
+}
+
+// devStars stars repos for a fake user, a week apart from now back
+func devStars(login string, repos []string, now time.Time) []gitHubStarredResponse {
+	stars := []gitHubStarredResponse{}
+	for i, repo := range repos {
+		stars = append(stars, gitHubStarredResponse{
+			StarredAt: now.AddDate(0, 0, -7*i),
+			Repo: gitHubRepository{
+				Repository: repo,
+				Private:    login == "private-eye",
+				// every fifth star looks abandoned
+				PushedAt: now.AddDate(-i%5, 0, 0),
+			},
+		})
+	}
+	return stars
+}
+
+func newFakeGitHub(stars map[string][]gitHubStarredResponse) *fakeGitHub {
+	return &fakeGitHub{stars: stars, revoked: map[string]bool{}}
+}
+
+// newDevGitHub returns the fake GitHub of dev mode, with the users of
+// devUsers, and a hoarder who starred the first devHoarderStars
+// repositories of the model as soon as it is loaded
+func newDevGitHub(now time.Time) *fakeGitHub {
+	g := newFakeGitHub(devUsers(now))
+	g.seedHoarder = true
+	return g
+}
+
+// seed adds the hoarder if the model is loaded and they are not there
+// yet. g must be locked.
+func (g *fakeGitHub) seed() {
+	if !g.seedHoarder {
+		return
+	}
+	m := currentModel()
+	if m == nil {
+		return
+	}
+	repos := m.Repositories()
+	if len(repos) > devHoarderStars {
+		repos = repos[:devHoarderStars]
+	}
+	g.stars["hoarder"] = devStars("hoarder", repos, time.Now())
+	g.seedHoarder = false
+}
+
+// revoke invalidates the token of a user, as when they revoke the access
+// of the app on GitHub
+func (g *fakeGitHub) revoke(login string) {
+	g.Lock()
+	defer g.Unlock()
+	g.revoked[login] = true
+}
+
+func (g *fakeGitHub) setDown(down bool) {
+	g.Lock()
+	defer g.Unlock()
+	g.down = down
+}
+
+func (g *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.Lock()
+	g.seed()
+	down := g.down
+	g.Unlock()
+	if down && strings.HasPrefix(r.URL.Path, "/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"message": "Server Error"}`)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/login/oauth/authorize":
+		g.authorize(w, r)
+	case r.URL.Path == "/login/oauth/access_token":
+		g.accessToken(w, r)
+	case r.URL.Path == "/api/user":
+		if login, ok := g.authenticate(w, r); ok {
+			json.NewEncoder(w).Encode(gitHubUserResponse{User: login})
+		}
+	case r.URL.Path == "/api/user/repos":
+		g.repositories(w, r)
+	case r.URL.Path == "/api/user/starred":
+		if login, ok := g.authenticate(w, r); ok {
+			g.starred(w, r, login, true)
+		}
+	case strings.HasPrefix(r.URL.Path, "/api/users/") && strings.HasSuffix(r.URL.Path, "/starred"):
+		g.starred(w, r, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/starred"), false)
+	case strings.HasPrefix(r.URL.Path, "/api/user/starred/"):
+		g.star(w, r, strings.TrimPrefix(r.URL.Path, "/api/user/starred/"))
+	case strings.HasPrefix(r.URL.Path, "/api/repos/") && strings.HasSuffix(r.URL.Path, "/readme"):
+		repo := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/repos/"), "/readme")
+		fmt.Fprintf(w, "# %s\n\nA fake README of %s, served by the dev mode GitHub.\n", repo, repo)
+	case strings.HasPrefix(r.URL.Path, "/api/repos/"):
+		g.repository(w, r, strings.TrimPrefix(r.URL.Path, "/api/repos/"))
+	case strings.HasSuffix(r.URL.Path, "/releases.atom"):
+		g.releases(w, r, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/releases.atom"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize lists the users to sign in as, and sends the one picked back
+// to the callback with their login as the code.
+func (g *fakeGitHub) authorize(w http.ResponseWriter, r *http.Request) {
+	if login := r.FormValue("login"); login != "" {
+		http.Redirect(w, r, "/callback?"+url.Values{
+			"code":  []string{login},
+			"state": []string{r.FormValue("state")},
+		}.Encode(), http.StatusFound)
+		return
+	}
+
+	type user struct {
+		Login string
+		URL   string
+		Stars int
+	}
+	var users []user
+	g.Lock()
+	for login, stars := range g.stars {
+		query := r.URL.Query()
+		query.Set("login", login)
+		users = append(users, user{login, devGitHubPrefix + r.URL.Path + "?" + query.Encode(), len(stars)})
+	}
+	g.Unlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].Login < users[j].Login })
+	fakeAuthorizeTemplate.Execute(w, users)
+}
+
+func (g *fakeGitHub) accessToken(w http.ResponseWriter, r *http.Request) {
+	login := r.FormValue("code")
+	g.Lock()
+	_, ok := g.stars[login]
+	delete(g.revoked, login)
+	g.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		json.NewEncoder(w).Encode(gitHubAccessTokenResponse{Error: "bad_verification_code"})
+		return
+	}
+	json.NewEncoder(w).Encode(gitHubAccessTokenResponse{AccessToken: devTokenPrefix + login, Scope: r.FormValue("scope")})
+}
+
+// authenticate returns the user of the token in the Authorization header
+func (g *fakeGitHub) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(strings.TrimPrefix(r.Header.Get("Authorization"), "token "), "Bearer ")
+	login := strings.TrimPrefix(token, devTokenPrefix)
+	g.Lock()
+	_, ok := g.stars[login]
+	ok = ok && !g.revoked[login]
+	g.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, `{"message": "Bad credentials"}`)
+		return "", false
+	}
+	return login, true
+}
+
+// starred lists the stars of a user, a page at a time. Only the user
+// sees their stars of private repositories.
+func (g *fakeGitHub) starred(w http.ResponseWriter, r *http.Request, login string, private bool) {
+	perPage, err := strconv.Atoi(r.FormValue("per_page"))
+	if err != nil || perPage <= 0 || perPage > 100 {
+		perPage = devPerPage
+	}
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	g.Lock()
+	stars, ok := g.stars[login]
+	g.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"message": "Not Found"}`)
+		return
+	}
+	if !private {
+		var public []gitHubStarredResponse
+		for _, star := range stars {
+			if !star.Repo.Private {
+				public = append(public, star)
+			}
+		}
+		stars = public
+	}
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(stars) {
+		start = len(stars)
+	}
+	if end >= len(stars) {
+		end = len(stars)
+	} else {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, gitHubAPIURL, strings.TrimPrefix(r.URL.Path, "/api"), query.Encode()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stars[start:end])
+}
+
+// repositories lists the repositories of a user: their private ones, which
+// are named after them, and their contributions
+func (g *fakeGitHub) repositories(w http.ResponseWriter, r *http.Request) {
+	login, ok := g.authenticate(w, r)
+	if !ok {
+		return
+	}
+	repos := []gitHubRepository{{Repository: login + "/private", Private: true, PushedAt: time.Now()}}
+	for _, repo := range devContributions[login] {
+		repos = append(repos, gitHubRepository{Repository: repo, PushedAt: time.Now()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(repos)
+}
+
+// repository serves the metadata of a repository, starred by the users
+// who starred it here
+func (g *fakeGitHub) repository(w http.ResponseWriter, r *http.Request, repo string) {
+	g.Lock()
+	stars := 0
+	for _, starred := range g.stars {
+		for _, star := range starred {
+			if strings.EqualFold(star.Repo.Repository, repo) {
+				stars++
+			}
+		}
+	}
+	g.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"full_name":        repo,
+		"stargazers_count": stars,
+		"description":      "A fake repository, served by the dev mode GitHub.",
+		"pushed_at":        time.Now().Add(-time.Duration(len(repo)) * 24 * time.Hour),
+	})
+}
+
+// star stars (PUT) or unstars (DELETE) a repository
+func (g *fakeGitHub) star(w http.ResponseWriter, r *http.Request, repo string) {
+	login, ok := g.authenticate(w, r)
+	if !ok {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	var stars []gitHubStarredResponse
+	for _, s := range g.stars[login] {
+		if s.Repo.Repository != repo {
+			stars = append(stars, s)
+		}
+	}
+	switch r.Method {
+	case "PUT":
+		star := gitHubStarredResponse{StarredAt: time.Now(), Repo: gitHubRepository{Repository: repo, PushedAt: time.Now()}}
+		stars = append([]gitHubStarredResponse{star}, stars...)
+	case "DELETE":
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	g.stars[login] = stars
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *fakeGitHub) releases(w http.ResponseWriter, r *http.Request, repo string) {
+	now := time.Now().UTC().Truncate(time.Hour)
+	feed := atomFeed{
+		ID:      "tag:github.com,2008:" + repo + "/releases",
+		Title:   "Release notes from " + repo,
+		Updated: now,
+		Entries: []atomEntry{{
+			ID:      "tag:github.com,2008:" + repo + "/v1.0.0",
+			Title:   "v1.0.0",
+			Updated: now,
+			Link:    []atomLink{{Rel: "alternate", Href: gitHubURL + "/" + repo + "/releases/tag/v1.0.0"}},
+		}},
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startDevServer serves the app with a fake GitHub, as in dev mode. stop
+// closes it and points the app back at GitHub.
+func startDevServer(t *testing.T, g *fakeGitHub) (server *httptest.Server, client *http.Client, stop func()) {
+	mux := http.NewServeMux()
+	mux.Handle(devGitHubPrefix+"/", http.StripPrefix(devGitHubPrefix, g))
+	mux.Handle("/", http.DefaultServeMux)
+	server = httptest.NewServer(mux)
+
+	savedURL, savedAPIURL := gitHubURL, gitHubAPIURL
+	gitHubURL = server.URL + devGitHubPrefix
+	gitHubAPIURL = gitHubURL + "/api"
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop = func() {
+		server.Close()
+		gitHubURL, gitHubAPIURL = savedURL, savedAPIURL
+	}
+	return server, &http.Client{Jar: jar}, stop
+}
+
+// signIn goes through the OAuth flow of the fake GitHub as login
+func signIn(t *testing.T, server *httptest.Server, client *http.Client, login string) {
+	resp, err := client.Get(server.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !strings.HasSuffix(resp.Request.URL.Path, "/login/oauth/authorize") {
+		t.Fatalf("Not sent to the fake GitHub: %s", resp.Request.URL)
+	}
+
+	query := resp.Request.URL.Query()
+	query.Set("login", login)
+	resp.Request.URL.RawQuery = query.Encode()
+	resp, err = client.Get(resp.Request.URL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/" {
+		t.Fatalf("Sign in as %s failed: %d %s", login, resp.StatusCode, resp.Request.URL)
+	}
+}
+
+func TestDevModeSignIn(t *testing.T) {
+	server, client, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+
+	resp, err := client.Get(gitHubURL + gitHubAuthorizePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, login := range []string{"webdev", "ml-researcher", "newbie", "hoarder"} {
+		if !strings.Contains(string(page), ">"+login+"<") {
+			t.Errorf("%s is not listed on the sign in page", login)
+		}
+	}
+
+	tests := []struct {
+		login string
+		stars int
+	}{
+		{"webdev", len(demoStars)},
+		{"newbie", 0},
+		// more than a page of the GitHub API
+		{"hoarder", 250},
+	}
+	for _, tt := range tests {
+		signIn(t, server, client, tt.login)
+		resp, err := client.Get(server.URL + "/api/v1/stars")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stars apiStarsResponse
+		err = json.NewDecoder(resp.Body).Decode(&stars)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if stars.User != tt.login || stars.Total != tt.stars {
+			t.Errorf("Wrong stars of %s: %s has %d", tt.login, stars.User, stars.Total)
+		}
+	}
+}
+
+func TestDevGitHubSeedsHoarderOnceLoaded(t *testing.T) {
+	m := currentModel()
+	setModel(nil)
+	defer setModel(m)
+	g := newDevGitHub(time.Now())
+
+	starred := func() int {
+		r := httptest.NewRequest("GET", "/api/user/starred", nil)
+		r.Header.Set("Authorization", "token dev-hoarder")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := starred(); code != http.StatusUnauthorized {
+		t.Errorf("Hoarder seeded without a model: %d", code)
+	}
+	setModel(m)
+	if code := starred(); code != http.StatusOK || len(g.stars["hoarder"]) != devHoarderStars {
+		t.Errorf("Hoarder not seeded once the model loaded: %d with %d stars", code, len(g.stars["hoarder"]))
+	}
+}
+
+func TestFakeGitHubStar(t *testing.T) {
+	g := newFakeGitHub(map[string][]gitHubStarredResponse{"newbie": {}})
+	for _, method := range []string{"PUT", "PUT", "DELETE"} {
+		r := httptest.NewRequest(method, "/api/user/starred/BVLC/caffe", nil)
+		r.Header.Set("Authorization", "token dev-newbie")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Wrong status for %s: %d", method, w.Code)
+		}
+		if method == "PUT" && len(g.stars["newbie"]) != 1 {
+			t.Errorf("Starring twice should star once: %v", g.stars["newbie"])
+		}
+	}
+	if len(g.stars["newbie"]) != 0 {
+		t.Errorf("Not unstarred: %v", g.stars["newbie"])
+	}
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/api/user/starred", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status without a token: %d", w.Code)
+	}
+}
+
+func TestCheckUsesTheRecommendationSettings(t *testing.T) {
+	server, client, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+	signIn(t, server, client, "ml-researcher")
+
+	getJSON := func(path string, v interface{}) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("Wrong response to %s: %d %v", path, resp.StatusCode, err)
+		}
+	}
+	var recs recommendationsTemplateVars
+	getJSON("/", &recs)
+	if len(recs.Recs) == 0 {
+		t.Fatalf("No recommendations")
+	}
+	repo := recs.Recs[0].Repository
+	owner := strings.SplitN(repo, "/", 2)[0]
+
+	var vars checkTemplateVars
+	getJSON("/check?repo="+repo, &vars)
+	if vars.Rank.Rank != 1 {
+		t.Errorf("%s is not ranked first: %v", repo, vars)
+	}
+	// the exclude cookie hides it, as on the recommendations page
+	getJSON("/?exclude="+owner, &recs)
+	vars = checkTemplateVars{}
+	getJSON("/check?repo="+repo, &vars)
+	want := `It is left out of your recommendations because it matches the exclude pattern "` + strings.ToLower(owner) + `/*".`
+	if vars.Rank.Rank != 0 || len(vars.Reasons) != 1 || vars.Reasons[0] != want {
+		t.Errorf("Wrong reasons for %s: %v", repo, vars.Reasons)
+	}
+}
@@ -0,0 +1,74 @@
+// Command batch-precompute precomputes the recommendations of a team with
+// the batch API, e.g. to send them in a weekly email. It reads GitHub
+// logins from the standard input, one per line, and writes a JSON object
+// per login to the standard output:
+//
+//	batch-precompute -api https://example.com -n 5 < team.txt > recs.jsonl
+//
+// The client sends the logins 100 at a time, and retries the batches that
+// fail while the server is busy.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jbochi/github-recs/recsclient"
+)
+
+type output struct {
+	User            string   `json:"user"`
+	Recommendations []string `json:"recommendations"`
+	ModelVersion    string   `json:"model_version"`
+}
+
+func main() {
+	api := flag.String("api", "http://localhost:8080", "the URL of the server")
+	n := flag.Int("n", 10, "the number of recommendations per user")
+	timeout := flag.Duration("timeout", 5*time.Minute, "how long to wait for all the recommendations")
+	flag.Parse()
+	log.SetFlags(0)
+
+	var requests []recsclient.BatchRequest
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if login := strings.TrimSpace(scanner.Text()); login != "" && !strings.HasPrefix(login, "#") {
+			requests = append(requests, recsclient.BatchRequest{User: login})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	results, err := recsclient.New(*api).RecommendBatch(ctx, requests, recsclient.N(*n))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			// e.g. users without public stars
+			log.Printf("Skipped %s: %s", result.User, result.Error)
+			failed++
+			continue
+		}
+		out := output{User: result.User, Recommendations: []string{}, ModelVersion: result.ModelVersion}
+		for _, rec := range result.Recommendations {
+			out.Recommendations = append(out.Recommendations, rec.Repository)
+		}
+		if err := encoder.Encode(out); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("Precomputed the recommendations of %d of %d users", len(results)-failed, len(results))
+}
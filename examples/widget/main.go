@@ -0,0 +1,110 @@
+// Command widget serves a "you might also like" widget for the page of a
+// repository, e.g. a project site or docs, to embed in an iframe:
+//
+//	<iframe src="http://localhost:8081/widget?repo=golang/go"></iframe>
+//
+// It gets the recommendations from the API and keeps them for a while,
+// so the widget is fast and the API is called once per repository, not
+// per visitor. When the API fails, the widget is left empty rather than
+// breaking the page it is on.
+package main
+
+import (
+	"context"
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jbochi/github-recs/lru"
+	"github.com/jbochi/github-recs/recsclient"
+)
+
+const (
+	// cacheFor is how long the recommendations of a repository are kept
+	cacheFor = time.Hour
+	// cacheBytes bounds the recommendations kept, as visitors pick the
+	// repositories
+	cacheBytes = 4 << 20
+	// apiTimeout bounds the API calls of a visit, retries included
+	apiTimeout = 5 * time.Second
+)
+
+var widgetTemplate = template.Must(template.New("widget").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<style>body { font: 14px sans-serif; margin: 0 } li { margin: 4px 0 }</style>
+{{ with .Recommendations }}
+<b>You might also like</b>
+<ul>
+  {{ range . }}<li><a href="https://github.com/{{ .Repository }}" target="_top">{{ .Repository }}</a></li>{{ end }}
+</ul>
+{{ end }}
+`))
+
+type (
+	cached struct {
+		recs    []recsclient.Recommendation
+		fetched time.Time
+	}
+
+	widget struct {
+		client *recsclient.Client
+		n      int
+		cache  *lru.Cache
+	}
+)
+
+func main() {
+	api := flag.String("api", "http://localhost:8080", "the URL of the server")
+	addr := flag.String("addr", ":8081", "the address to serve the widget on")
+	n := flag.Int("n", 5, "the number of repositories in the widget")
+	flag.Parse()
+
+	client := recsclient.New(*api)
+	// visitors do not wait for long retries
+	client.MaxRetries, client.MaxBackoff = 2, time.Second
+	http.Handle("/widget", &widget{client: client, n: *n, cache: lru.New(cacheBytes)})
+	log.Printf("Serving the widget on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (wg *widget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repo := r.FormValue("repo")
+	if repo == "" {
+		http.Error(w, "Missing the repo parameter", http.StatusBadRequest)
+		return
+	}
+	recs, err := wg.recommendations(r.Context(), repo)
+	if err != nil {
+		log.Printf("Unable to recommend for %s: %v", repo, err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	widgetTemplate.Execute(w, struct{ Recommendations []recsclient.Recommendation }{recs})
+}
+
+// recommendations returns the cached recommendations for a repository,
+// getting them from the API when they are missing or too old
+func (wg *widget) recommendations(ctx context.Context, repo string) ([]recsclient.Recommendation, error) {
+	var c cached
+	if value, ok := wg.cache.Get(repo); ok {
+		if c = value.(cached); time.Since(c.fetched) < cacheFor {
+			return c.recs, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+	recs, err := wg.client.Recommend(ctx, []string{repo}, recsclient.N(wg.n))
+	if err != nil {
+		// serve the old ones rather than none
+		return c.recs, err
+	}
+	size := int64(len(repo))
+	for _, rec := range recs.Recommendations {
+		size += int64(len(rec.Repository)) + 64
+	}
+	wg.cache.Add(repo, cached{recs.Recommendations, time.Now()}, size)
+	return recs.Recommendations, nil
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+type (
+	opml struct {
+		XMLName xml.Name      `xml:"opml"`
+		Version string        `xml:"version,attr"`
+		Title   string        `xml:"head>title"`
+		Created string        `xml:"head>dateCreated"`
+		Feeds   []opmlOutline `xml:"body>outline"`
+	}
+
+	opmlOutline struct {
+		Type    string `xml:"type,attr"`
+		Text    string `xml:"text,attr"`
+		Title   string `xml:"title,attr"`
+		XMLURL  string `xml:"xmlUrl,attr"`
+		HTMLURL string `xml:"htmlUrl,attr"`
+	}
+)
+
+var bookmarksTemplate = template.Must(template.New("bookmarks").Funcs(tplFuncs).Parse(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="{{.Created}}">{{.Title}}</H3>
+    <DL><p>
+{{- range .Recs }}
+        <DT><A HREF="{{ repositoryURL .Repository }}" ADD_DATE="{{$.Created}}">{{ .Repository }}</A>
+{{- end }}
+    </DL><p>
+</DL><p>
+`))
+
+// releasesFeedURL returns the Atom feed of the releases of the repository
+// an entity belongs to.
+func releasesFeedURL(repo string) string {
+	parts := strings.SplitN(repo, "/", 3)
+	return gitHubURL + "/" + parts[0] + "/" + parts[1] + "/releases.atom"
+}
+
+// writeOPML writes the recommendations as an OPML subscription list of
+// their releases feeds, for feed readers.
+func writeOPML(w io.Writer, title string, recs []RepositoryScore, now time.Time) error {
+	doc := opml{Version: "2.0", Title: title, Created: now.UTC().Format(time.RFC1123Z)}
+	for _, rec := range recs {
+		doc.Feeds = append(doc.Feeds, opmlOutline{
+			Type:    "rss",
+			Text:    rec.Repository,
+			Title:   rec.Repository,
+			XMLURL:  releasesFeedURL(rec.Repository),
+			HTMLURL: recommender.RepositoryURL(rec.Repository),
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeBookmarks writes the recommendations as a Netscape bookmarks file,
+// which every browser can import.
+func writeBookmarks(w io.Writer, title string, recs []RepositoryScore, now time.Time) error {
+	return bookmarksTemplate.Execute(w, struct {
+		Title   string
+		Created int64
+		Recs    []RepositoryScore
+	}{title, now.Unix(), recs})
+}
+
+// export downloads the current recommendations of the user with
+// ?format=opml or ?format=bookmarks.
+func export(w http.ResponseWriter, r *http.Request) {
+	var write func(io.Writer, string, []RepositoryScore, time.Time) error
+	var contentType, filename string
+	switch format := r.FormValue("format"); format {
+	case "opml":
+		write, contentType, filename = writeOPML, "text/x-opml; charset=utf-8", "github-recs.opml"
+	case "bookmarks":
+		write, contentType, filename = writeBookmarks, "text/html; charset=utf-8", "github-recs-bookmarks.html"
+	default:
+		httpError(w, r, fmt.Sprintf("Unknown export format %q, expected opml or bookmarks", format), http.StatusBadRequest)
+		return
+	}
+
+	since, err := starsSince(r, time.Now())
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var starredRepos []gitHubRepository
+	user, err := authenticatedUser(r)
+	if err == nil {
+		starredRepos, err = starredRepositories(r)
+	}
+	if err != nil {
+		loginRequired(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", privateCacheControl)
+	model, version := modelForRequest(r)
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+	stars := repositoryNames(starredSince(starredRepos, since))
+	recs, err := model.Recommend(r.Context(), stars, numRecommendations, recommender.ExcludePatterns(excludeCookie(r)...), recommender.ExcludeRepositories(repositoryNames(starredRepos)...), recommender.Filters(filters...))
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := write(w, fmt.Sprintf("GitHub recommendations for %s", user), recs, time.Now()); err != nil {
+		logWarningf("Failed to write export: %v", err)
+	}
+}
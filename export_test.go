@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportRecs = []RepositoryScore{
+	{ID: 1, Repository: "BVLC/caffe", Score: 0.9},
+	{ID: 2, Repository: "google/research/bert", Score: 0.8},
+}
+
+func TestWriteOPML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeOPML(&buf, "Recs for <me>", exportRecs, time.Unix(0, 0)); err != nil {
+		t.Fatalf("Failed to write OPML: %v", err)
+	}
+	var doc opml
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid OPML: %v\n%s", err, buf.String())
+	}
+	if doc.Title != "Recs for <me>" || len(doc.Feeds) != 2 {
+		t.Fatalf("Wrong OPML: %+v", doc)
+	}
+	if doc.Feeds[1].XMLURL != "https://github.com/google/research/releases.atom" {
+		t.Errorf("Wrong feed URL: %v", doc.Feeds[1].XMLURL)
+	}
+	if doc.Feeds[1].HTMLURL != "https://github.com/google/research/tree/HEAD/bert" {
+		t.Errorf("Wrong HTML URL: %v", doc.Feeds[1].HTMLURL)
+	}
+}
+
+func TestWriteBookmarks(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeBookmarks(&buf, "Recs for <me>", exportRecs, time.Unix(1500000000, 0)); err != nil {
+		t.Fatalf("Failed to write bookmarks: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<!DOCTYPE NETSCAPE-Bookmark-file-1>",
+		`<H3 ADD_DATE="1500000000">Recs for &lt;me&gt;</H3>`,
+		`<A HREF="https://github.com/BVLC/caffe" ADD_DATE="1500000000">BVLC/caffe</A>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Bookmarks are missing %q:\n%s", want, out)
+		}
+	}
+}
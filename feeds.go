@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxFeedRepositories = 20
+	maxFeedEntries      = 50
+)
+
+type (
+	atomFeed struct {
+		XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string      `xml:"id"`
+		Title   string      `xml:"title"`
+		Updated time.Time   `xml:"updated"`
+		Link    []atomLink  `xml:"link"`
+		Entries []atomEntry `xml:"entry"`
+	}
+
+	atomEntry struct {
+		ID      string     `xml:"id"`
+		Title   string     `xml:"title"`
+		Updated time.Time  `xml:"updated"`
+		Link    []atomLink `xml:"link"`
+		Author  *atomName  `xml:"author,omitempty"`
+		Content *atomText  `xml:"content,omitempty"`
+	}
+
+	atomLink struct {
+		Rel  string `xml:"rel,attr,omitempty"`
+		Type string `xml:"type,attr,omitempty"`
+		Href string `xml:"href,attr"`
+	}
+
+	atomName struct {
+		Name string `xml:"name"`
+	}
+
+	atomText struct {
+		Type string `xml:"type,attr,omitempty"`
+		Body string `xml:",chardata"`
+	}
+)
+
+// releasesFeedPath returns the path of the merged releases feed of the
+// given repositories.
+func releasesFeedPath(recs []RepositoryScore) string {
+	var repos []string
+	for _, rec := range recs {
+		repos = append(repos, rec.Repository)
+	}
+	return "/releases.atom?" + url.Values{"repos": []string{strings.Join(repos, ",")}}.Encode()
+}
+
+// mergeFeeds combines the entries of several feeds, newest first. Entry
+// titles are prefixed with the repository they come from.
+func mergeFeeds(feeds map[string]*atomFeed, limit int) []atomEntry {
+	var entries []atomEntry
+	for repo, feed := range feeds {
+		for _, entry := range feed.Entries {
+			entry.Title = repo + ": " + entry.Title
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Updated.After(entries[j].Updated)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// releasesFeed serves a single Atom feed with the releases of the
+// repositories in the repos parameter. Feed readers fetch it without the
+// user's cookies, so the list of repositories is part of the URL, and it
+// is restricted to repositories known by the model.
+func releasesFeed(w http.ResponseWriter, r *http.Request) {
+	model := currentModel()
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	var repos []string
+	seen := map[string]bool{}
+	for _, ref := range strings.Split(r.FormValue("repos"), ",") {
+		repo := model.Repository(ref)
+		if repo == "" {
+			continue
+		}
+		// sub-path entities share the releases of their repository
+		parts := strings.SplitN(repo, "/", 3)
+		repo = parts[0] + "/" + parts[1]
+		if !seen[repo] {
+			seen[repo] = true
+			repos = append(repos, repo)
+		}
+	}
+	if len(repos) == 0 {
+		httpError(w, r, "No known repositories in the repos parameter", http.StatusBadRequest)
+		return
+	}
+	if len(repos) > maxFeedRepositories {
+		httpError(w, r, fmt.Sprintf("At most %d repositories are allowed", maxFeedRepositories), http.StatusBadRequest)
+		return
+	}
+
+	feeds := map[string]*atomFeed{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, repo := range repos {
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			feed, err := fetchFeed(httpClient, releasesFeedURL(repo))
+			if err != nil {
+				logWarningf("Unable to fetch releases of %s: %v", repo, err)
+				return
+			}
+			mu.Lock()
+			feeds[repo] = feed
+			mu.Unlock()
+		}(repo)
+	}
+	wg.Wait()
+
+	selfURL := "https://" + r.Host + r.URL.RequestURI()
+	merged := atomFeed{
+		ID:      selfURL,
+		Title:   "Releases of " + strings.Join(repos, ", "),
+		Updated: time.Now().UTC(),
+		Link:    []atomLink{{Rel: "self", Href: selfURL}},
+		Entries: mergeFeeds(feeds, maxFeedEntries),
+	}
+	if len(merged.Entries) > 0 {
+		merged.Updated = merged.Entries[0].Updated
+	}
+
+	setPublicCacheHeaders(w, "releases")
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(merged); err != nil {
+		logWarningf("Failed to write feed: %v", err)
+	}
+}
+
+func fetchFeed(client *http.Client, url string) (*atomFeed, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status: %s", resp.Status)
+	}
+	var feed atomFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestMergeFeeds(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 8, d, 0, 0, 0, 0, time.UTC) }
+	feeds := map[string]*atomFeed{
+		"BVLC/caffe":  {Entries: []atomEntry{{Title: "1.0", Updated: day(1)}, {Title: "1.1", Updated: day(5)}}},
+		"golang/go":   {Entries: []atomEntry{{Title: "go1.9", Updated: day(3)}}},
+		"nodejs/node": {},
+	}
+	entries := mergeFeeds(feeds, 2)
+	if len(entries) != 2 {
+		t.Fatalf("Wrong number of entries: %v", entries)
+	}
+	if entries[0].Title != "BVLC/caffe: 1.1" || entries[1].Title != "golang/go: go1.9" {
+		t.Errorf("Wrong entries: %v", entries)
+	}
+}
+
+func TestParseAtomFeed(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:github.com,2008:https://github.com/BVLC/caffe/releases</id>
+  <title>Release notes from caffe</title>
+  <updated>2017-04-19T01:49:42Z</updated>
+  <entry>
+    <id>tag:github.com,2008:Repository/61605/1.0</id>
+    <updated>2017-04-19T01:49:42Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/BVLC/caffe/releases/tag/1.0"/>
+    <title>Caffe 1.0</title>
+    <content type="html">&lt;p&gt;notes&lt;/p&gt;</content>
+    <author><name>shelhamer</name></author>
+  </entry>
+</feed>`
+	var feed atomFeed
+	if err := xml.Unmarshal([]byte(data), &feed); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(feed.Entries) != 1 || feed.Entries[0].Title != "Caffe 1.0" || feed.Entries[0].Link[0].Href != "https://github.com/BVLC/caffe/releases/tag/1.0" {
+		t.Errorf("Wrong feed: %+v", feed)
+	}
+	if _, err := xml.Marshal(feed); err != nil {
+		t.Errorf("Failed to write feed: %v", err)
+	}
+}
+
+func TestReleasesFeedPath(t *testing.T) {
+	got := releasesFeedPath([]RepositoryScore{{Repository: "BVLC/caffe"}, {Repository: "golang/go"}})
+	if got != "/releases.atom?repos=BVLC%2Fcaffe%2Cgolang%2Fgo" {
+		t.Errorf("Wrong path: %v", got)
+	}
+}
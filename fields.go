@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type recommendationsFieldsResponse struct {
+	User  string                   `json:"user"`
+	Stars []string                 `json:"stars"`
+	Recs  []map[string]interface{} `json:"recommendations"`
+	// the version of the model that made the recommendations
+	ModelVersion string `json:"model_version,omitempty"`
+}
+
+// recommendationFields maps the names accepted by the fields parameter to
+// the JSON fields of a recommendation
+var recommendationFields = map[string]string{
+	"id":         "id",
+	"repo":       "repository",
+	"repository": "repository",
+	"score":      "score",
+	"summary":    "summary",
+}
+
+// parseFields returns the recommendation fields requested with
+// ?fields=repo,score, or nil if the parameter is absent.
+func parseFields(r *http.Request) ([]string, error) {
+	value := r.FormValue("fields")
+	if value == "" {
+		return nil, nil
+	}
+	fields := []string{}
+	for _, name := range strings.Split(value, ",") {
+		field, ok := recommendationFields[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown field %q", name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFields builds partial recommendations with only the given fields
+func selectFields(recs []RepositoryScore, summaries map[string]string, fields []string) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(recs))
+	for i, rec := range recs {
+		item := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			switch field {
+			case "id":
+				item[field] = rec.ID
+			case "repository":
+				item[field] = rec.Repository
+			case "score":
+				item[field] = rec.Score
+			case "summary":
+				item[field] = summaries[rec.Repository]
+			}
+		}
+		result[i] = item
+	}
+	return result
+}
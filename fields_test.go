@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+		err   bool
+	}{
+		{"", nil, false},
+		{"fields=repo,score", []string{"repository", "score"}, false},
+		{"fields=id,%20summary", []string{"id", "summary"}, false},
+		{"fields=repo,stars", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFields(httptest.NewRequest("GET", "/?"+tt.query, nil))
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFields(%q) = %v, %v; want %v", tt.query, got, err, tt.want)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	recs := []RepositoryScore{{ID: 1, Repository: "BVLC/caffe", Score: 0.5}}
+	got := selectFields(recs, map[string]string{"BVLC/caffe": "A framework."}, []string{"repository", "summary"})
+	want := []map[string]interface{}{{"repository": "BVLC/caffe", "summary": "A framework."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectFields = %v, want %v", got, want)
+	}
+}
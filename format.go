@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLocale formats numbers when the client accepts no other locale
+// of localeNumberFormats
+const defaultLocale = "en"
+
+// numberFormat is how a locale separates the thousands and the decimals
+// of numbers
+type numberFormat struct {
+	group, decimal string
+}
+
+var (
+	// localeNumberFormats are the locales numbers are formatted in, by
+	// language
+	localeNumberFormats = map[string]numberFormat{
+		"de": {".", ","},
+		"en": {",", "."},
+		"es": {".", ","},
+		"fr": {"\u202f", ","},
+		"it": {".", ","},
+		"ja": {",", "."},
+		"nl": {".", ","},
+		"pt": {".", ","},
+		"ru": {"\u00a0", ","},
+		"zh": {",", "."},
+	}
+
+	// localizedTemplates are the templates with the formatting functions
+	// of the locales other than defaultLocale, by locale and name
+	localizedTemplates = localizeTemplates(tpl)
+)
+
+// requestLocale returns the locale numbers are formatted in for a request:
+// the locale parameter, or the first language of the Accept-Language
+// header with a format, or defaultLocale
+func requestLocale(r *http.Request) string {
+	if locale := strings.ToLower(r.URL.Query().Get("locale")); locale != "" {
+		if _, ok := localeNumberFormats[locale]; ok {
+			return locale
+		}
+	}
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(tag, ";")
+		language := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(language, "-_"); i >= 0 {
+			language = language[:i]
+		}
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) == "q=0" {
+			continue
+		}
+		if _, ok := localeNumberFormats[language]; ok {
+			return language
+		}
+	}
+	return defaultLocale
+}
+
+// formattedLocale returns the locale of the preformatted strings of an API
+// response, set with formatted=true, or "" if they were not requested
+func formattedLocale(r *http.Request) (string, error) {
+	value := r.FormValue("formatted")
+	if value == "" {
+		return "", nil
+	}
+	formatted, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid formatted %q, expected true or false", value)
+	}
+	if !formatted {
+		return "", nil
+	}
+	return requestLocale(r), nil
+}
+
+// formatNumber writes an integer with the thousands separator of the
+// locale, as in 12,345
+func formatNumber(locale string, n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	group := localeNumberFormats[locale].group
+	var b strings.Builder
+	for i, digit := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String()
+}
+
+// formatDecimal writes a number with the given number of decimals and the
+// separators of the locale, as in 1,234.56
+func formatDecimal(locale string, f float64, decimals int) string {
+	s := strconv.FormatFloat(f, 'f', decimals, 64)
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	n, _ := strconv.Atoi(integer)
+	formatted := formatNumber(locale, n)
+	if n == 0 && strings.HasPrefix(integer, "-") {
+		formatted = "-0"
+	}
+	if fraction == "" {
+		return formatted
+	}
+	return formatted + localeNumberFormats[locale].decimal + fraction
+}
+
+// formatCount writes a count compactly, as in 12.3k or 4.5M, with the
+// decimal separator of the locale, and counts under 1000 as they are
+func formatCount(locale string, n int) string {
+	abs := math.Abs(float64(n))
+	var value float64
+	var suffix string
+	switch {
+	case abs < 1e3:
+		return strconv.Itoa(n)
+	case abs < 999950:
+		value, suffix = float64(n)/1e3, "k"
+	default:
+		value, suffix = float64(n)/1e6, "M"
+	}
+	s := formatDecimal(locale, value, 1)
+	// 12.0k is 12k
+	s = strings.TrimSuffix(s, localeNumberFormats[locale].decimal+"0")
+	return s + suffix
+}
+
+// formatRelative writes how long before now a time is, as in "3 weeks
+// ago", rounding down to the largest unit
+func formatRelative(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < time.Minute {
+		return "just now"
+	}
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if n := int(d / unit.d); n >= 1 {
+			if n == 1 {
+				if unit.name == "day" {
+					return "yesterday"
+				}
+				return "1 " + unit.name + " ago"
+			}
+			return fmt.Sprintf("%d %ss ago", n, unit.name)
+		}
+	}
+	return "just now"
+}
+
+// formatFuncs are the formatting functions of the templates, in a locale
+func formatFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"number":  func(n int) string { return formatNumber(locale, n) },
+		"count":   func(n int) string { return formatCount(locale, n) },
+		"decimal": func(f float64, decimals int) string { return formatDecimal(locale, f, decimals) },
+		"timeAgo": func(t time.Time) string { return formatRelative(t, time.Now()) },
+	}
+}
+
+// localizeTemplates clones the templates with the formatting functions of
+// each locale but defaultLocale, by locale and name. Templates can only be
+// cloned before they are executed, so this runs as they are parsed.
+func localizeTemplates(templates map[string]*template.Template) map[string]map[string]*template.Template {
+	localized := map[string]map[string]*template.Template{}
+	for locale := range localeNumberFormats {
+		if locale == defaultLocale {
+			continue
+		}
+		localized[locale] = map[string]*template.Template{}
+		for name, t := range templates {
+			localized[locale][name] = template.Must(t.Clone()).Funcs(formatFuncs(locale))
+		}
+	}
+	return localized
+}
+
+// localizedTemplate returns the named template with the formatting
+// functions of a locale
+func localizedTemplate(name, locale string) *template.Template {
+	if t, ok := localizedTemplates[locale][name]; ok {
+		return t
+	}
+	return tpl[name]
+}
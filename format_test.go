@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatNumbers(t *testing.T) {
+	for _, test := range []struct {
+		got, expected string
+	}{
+		{formatNumber("en", 0), "0"},
+		{formatNumber("en", 999), "999"},
+		{formatNumber("en", 1234567), "1,234,567"},
+		{formatNumber("de", -12345), "-12.345"},
+		{formatNumber("fr", 12345), "12 345"},
+		{formatDecimal("en", 1234.567, 2), "1,234.57"},
+		{formatDecimal("de", 0.5, 2), "0,50"},
+		{formatDecimal("en", -0.25, 1), "-0.2"},
+		{formatDecimal("en", 3, 0), "3"},
+		{formatCount("en", 999), "999"},
+		{formatCount("en", 1000), "1k"},
+		{formatCount("en", 12345), "12.3k"},
+		{formatCount("de", 12345), "12,3k"},
+		{formatCount("en", 999999), "1M"},
+		{formatCount("en", 4500000), "4.5M"},
+	} {
+		if test.got != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, test.got)
+		}
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for d, expected := range map[time.Duration]string{
+		-time.Hour:               "just now",
+		30 * time.Second:         "just now",
+		time.Minute:              "1 minute ago",
+		5 * time.Hour:            "5 hours ago",
+		30 * time.Hour:           "yesterday",
+		3 * 24 * time.Hour:       "3 days ago",
+		22 * 24 * time.Hour:      "3 weeks ago",
+		65 * 24 * time.Hour:      "2 months ago",
+		2 * 365 * 24 * time.Hour: "2 years ago",
+	} {
+		if got := formatRelative(now.Add(-d), now); got != expected {
+			t.Errorf("Expected %q %v ago, got %q", expected, d, got)
+		}
+	}
+}
+
+func TestRequestLocale(t *testing.T) {
+	for _, test := range []struct {
+		url, acceptLanguage, expected string
+	}{
+		{"/", "", "en"},
+		{"/", "pt-BR,pt;q=0.9,en;q=0.8", "pt"},
+		{"/", "xx, de_DE;q=0.5", "de"},
+		{"/", "fr;q=0, it", "it"},
+		{"/?locale=ru", "de", "ru"},
+		{"/?locale=xx", "de", "de"},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		r.Header.Set("Accept-Language", test.acceptLanguage)
+		if locale := requestLocale(r); locale != test.expected {
+			t.Errorf("Wrong locale of %s with %q: %q", test.url, test.acceptLanguage, locale)
+		}
+	}
+}
+
+func TestLocalizedTemplates(t *testing.T) {
+	vars := recommendationsTemplateVars{
+		User:  "octocat",
+		Stars: []string{"golang/go"},
+		Recs:  []RepositoryScore{{Repository: "golang/go", Score: 0.5}},
+		Repositories: map[string]*repositoryInfo{
+			"golang/go": {Stars: 12345, PushedAt: time.Now().Add(-22 * 24 * time.Hour)},
+		},
+	}
+	for locale, expected := range map[string][]string{
+		"en": {"(0.50)", "12.3k stars, updated 3 weeks ago"},
+		"de": {"(0,50)", "12,3k stars"},
+	} {
+		var buf bytes.Buffer
+		if err := localizedTemplate("recs", locale).ExecuteTemplate(&buf, "base.html", vars); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("Expected %q in the %s page", s, locale)
+			}
+		}
+	}
+}
+
+func TestAPIFormatted(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/recommendations?repos=tensorflow/tensorflow&n=1&formatted=true", nil)
+	r.Header.Set("Accept-Language", "de-DE")
+	w := httptest.NewRecorder()
+	apiRecommendations(w, r)
+	var response apiRecommendationsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(response.Recommendations) != 1 || response.Recommendations[0].Formatted["score"] != formatDecimal("de", response.Recommendations[0].Score, 2) {
+		t.Errorf("Wrong formatted recommendations: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=tensorflow/tensorflow&n=1", nil))
+	if strings.Contains(w.Body.String(), "formatted") {
+		t.Errorf("Unrequested formatted recommendations: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=tensorflow/tensorflow&formatted=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Wrong status for an invalid formatted: %d", w.Code)
+	}
+}
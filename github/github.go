@@ -0,0 +1,140 @@
+// Package github fetches the public stars of GitHub users, for programs
+// that seed recommendations with them without running the server:
+//
+//	client := github.New(os.Getenv("GITHUB_TOKEN"))
+//	stars, err := client.PublicStars(ctx, "octocat")
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultAPIURL is where the GitHub API is
+	DefaultAPIURL = "https://api.github.com"
+	// DefaultMaxPages is how many pages of 100 stars are fetched at most,
+	// as the server does by default
+	DefaultMaxPages = 10
+
+	publicStarredPath = "/users/%s/starred?per_page=100"
+	userAgent         = "github-recs"
+)
+
+var (
+	// ErrUnknownUser is returned for the stars of a user GitHub does not
+	// know
+	ErrUnknownUser = errors.New("Unknown GitHub user")
+	// ErrRateLimited is returned while GitHub rate limits the requests,
+	// which a token raises from 60 to 5000 an hour
+	ErrRateLimited = errors.New("GitHub rate limit exceeded")
+
+	login = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+)
+
+// Client calls the public GitHub API. Its fields are set by New and may be
+// changed before it is used; it is then safe for concurrent use.
+type Client struct {
+	// APIURL is the URL of the GitHub API, without a trailing slash
+	APIURL string
+	// HTTPClient sends the requests
+	HTTPClient *http.Client
+	// Token authenticates the requests if set, to raise their rate limit.
+	// It needs no scope.
+	Token string
+	// MaxPages is how many pages of 100 stars are fetched at most
+	MaxPages int
+}
+
+// New returns a client of the GitHub API, authenticated with token unless
+// it is ""
+func New(token string) *Client {
+	return &Client{
+		APIURL:     DefaultAPIURL,
+		HTTPClient: http.DefaultClient,
+		Token:      token,
+		MaxPages:   DefaultMaxPages,
+	}
+}
+
+// PublicStars returns the public repositories a GitHub user starred, most
+// recent first, following the pages of stars up to MaxPages
+func (c *Client) PublicStars(ctx context.Context, user string) ([]string, error) {
+	if !login.MatchString(user) {
+		return nil, fmt.Errorf("Invalid GitHub user %q", user)
+	}
+	var stars []string
+	next := c.APIURL + fmt.Sprintf(publicStarredPath, user)
+	for page := 0; next != "" && page < c.MaxPages; page++ {
+		var repos []struct {
+			FullName string `json:"full_name"`
+		}
+		var err error
+		next, err = c.get(ctx, next, &repos)
+		if err != nil {
+			return stars, err
+		}
+		for _, repo := range repos {
+			stars = append(stars, repo.FullName)
+		}
+	}
+	return stars, nil
+}
+
+// get decodes the JSON response to a GET of url into result, and returns
+// the URL of the next page, if there is one
+func (c *Client) get(ctx context.Context, url string, result interface{}) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", userAgent)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrUnknownUser
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.StatusCode == http.StatusTooManyRequests {
+			return "", ErrRateLimited
+		}
+		fallthrough
+	default:
+		return "", fmt.Errorf("Unexpected status from GitHub: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// nextPageURL returns the URL of the next page in a Link header, or ""
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		sections := strings.Split(part, ";")
+		target := strings.TrimSpace(sections[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range sections[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
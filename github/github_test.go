@@ -0,0 +1,51 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPublicStars(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/nobody/starred" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/users/busy/starred" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Wrong authorization: %q", r.Header.Get("Authorization"))
+		}
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		w.Header().Set("Link", fmt.Sprintf(`<%s/users/octocat/starred?per_page=100&page=%d>; rel="next"`, server.URL, page+1))
+		fmt.Fprintf(w, `[{"full_name": "a/%d"}, {"full_name": "b/%d"}]`, page, page)
+	}))
+	defer server.Close()
+	c := New("secret")
+	c.APIURL, c.MaxPages = server.URL, 3
+
+	stars, err := c.PublicStars(context.Background(), "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(stars) != "[a/0 b/0 a/1 b/1 a/2 b/2]" {
+		t.Errorf("Wrong stars: %v", stars)
+	}
+
+	for user, expected := range map[string]error{"nobody": ErrUnknownUser, "busy": ErrRateLimited} {
+		if _, err := c.PublicStars(context.Background(), user); err != expected {
+			t.Errorf("Wrong error for %s: %v", user, err)
+		}
+	}
+	if _, err := c.PublicStars(context.Background(), "../admin"); err == nil {
+		t.Errorf("Expected an error for an invalid user")
+	}
+}
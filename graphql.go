@@ -0,0 +1,664 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jbochi/github-recs/graphql"
+	"github.com/jbochi/github-recs/lru"
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	// maxGraphQLBody caps the size of a GraphQL request
+	maxGraphQLBody = 64 << 10
+	// maxGraphQLFields caps the fields resolved for a query, so nested
+	// similar repositories cannot multiply without bound
+	maxGraphQLFields = 10000
+	// maxGraphQLRecommendations caps the recommendations fields of a
+	// query, each of which may fetch the stars of a user from GitHub and
+	// scores the whole model, as the batch API caps its requests
+	maxGraphQLRecommendations = maxBatchRequests
+	// maxGraphQLSimilar caps the similar fields of a query, which nest
+	// lists of up to maxAPIRecommendations repositories in each other
+	maxGraphQLSimilar = 10
+	// maxGraphQLGitHubRepositories caps the repositories whose stars,
+	// description or summary a query fetches from GitHub, with the token
+	// of the app, a page of recommendations worth
+	maxGraphQLGitHubRepositories = maxAPIRecommendations
+	// graphqlWorkers caps the goroutines resolving the lists of a query
+	graphqlWorkers = 8
+	// gitHubRepositoryPath is the metadata of a repository, under
+	// gitHubAPIURL
+	gitHubRepositoryPath = "/repos/%s"
+	// repositoryInfoMaxAge is how long the stars and description of a
+	// repository are served before they are fetched again
+	repositoryInfoMaxAge = time.Hour
+	// repositoryInfoCacheBytes bounds the repository metadata kept
+	repositoryInfoCacheBytes = 4 << 20
+)
+
+// graphqlSchema is the schema /graphql serves, and returns to GET
+// requests without a query
+const graphqlSchema = `type Query {
+  # recommendations for the seed repositories in repos, or the public stars
+  # of a GitHub user, or the stars of the signed in user without either
+  recommendations(repos: [String!], user: String, n: Int = 10, lang: [String!], topic: [String!]): Recommendations!
+  # a repository the model knows, or null
+  repository(name: String!): Repository
+}
+
+type Recommendations {
+  user: String
+  # the repositories the recommendations are for
+  items: [String!]!
+  # the seed repositories the model does not know, left out
+  unknown: [String!]!
+  modelVersion: String
+  recommendations: [Recommendation!]!
+}
+
+type Recommendation {
+  rank: Int!
+  score: Float!
+  # the score in the locale of the client, e.g. "0,57" in German
+  formattedScore: String!
+  # why it is recommended, in a sentence
+  explanation: String
+  # the items that contributed the most to the score
+  because: [Repository!]!
+  repository: Repository!
+}
+
+type Repository {
+  name: String!
+  id: ID!
+  url: String!
+  # lowercased, empty if the model has no languages.csv or topics.csv
+  languages: [String!]!
+  topics: [String!]!
+  # fetched from GitHub
+  stars: Int
+  description: String
+  # the stars in the locale of the client, e.g. "12.3k"
+  formattedStars: String
+  # when it was last pushed to, e.g. "3 weeks ago"
+  updated: String
+  # the summary of the README, for signed in users
+  summary: String
+  similar(n: Int = 10): [Recommendation!]!
+}
+`
+
+type (
+	graphqlRequest struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+
+	graphqlResponse struct {
+		Data   interface{}      `json:"data,omitempty"`
+		Errors []*graphql.Error `json:"errors,omitempty"`
+	}
+
+	// graphqlType lists the fields of a type of graphqlSchema, and their
+	// arguments. Fields with an object type have to select its fields.
+	graphqlType struct {
+		name   string
+		fields map[string]graphqlField
+	}
+
+	graphqlField struct {
+		object    bool
+		arguments []string
+	}
+
+	// graphqlExecution is the state of a query, whose lists are resolved
+	// concurrently
+	graphqlExecution struct {
+		r       *http.Request
+		model   *recommender.Model
+		version string
+		// the locale numbers are formatted in
+		locale string
+		// the fields, recommendations and similar lists resolved so far
+		fields               int32
+		recommendationFields int32
+		similarFields        int32
+		// a slot for each goroutine resolving a list item
+		workers chan struct{}
+		mu      sync.Mutex
+		errors  []*graphql.Error
+		// the repositories whose metadata was fetched from GitHub
+		gitHubRepositories map[string]bool
+	}
+
+	// graphqlRecommendation is a recommendation, with the subject of its
+	// explanation, e.g. "you starred"
+	graphqlRecommendation struct {
+		RepositoryScore
+		rank    int
+		subject string
+	}
+
+	// repositoryInfo is the metadata of a repository fetched from GitHub
+	repositoryInfo struct {
+		Stars       int       `json:"stargazers_count"`
+		Description string    `json:"description"`
+		PushedAt    time.Time `json:"pushed_at"`
+		fetched     time.Time
+	}
+)
+
+var (
+	graphqlTypes = map[string]graphqlType{
+		"Query": {"Query", map[string]graphqlField{
+			"recommendations": {true, []string{"repos", "user", "n", "lang", "topic"}},
+			"repository":      {true, []string{"name"}},
+		}},
+		"Recommendations": {"Recommendations", map[string]graphqlField{
+			"user":            {},
+			"items":           {},
+			"unknown":         {},
+			"modelVersion":    {},
+			"recommendations": {object: true},
+		}},
+		"Recommendation": {"Recommendation", map[string]graphqlField{
+			"rank":           {},
+			"score":          {},
+			"formattedScore": {},
+			"explanation":    {},
+			"because":        {object: true},
+			"repository":     {object: true},
+		}},
+		"Repository": {"Repository", map[string]graphqlField{
+			"name":           {},
+			"id":             {},
+			"url":            {},
+			"languages":      {},
+			"topics":         {},
+			"stars":          {},
+			"description":    {},
+			"formattedStars": {},
+			"updated":        {},
+			"summary":        {},
+			"similar":        {true, []string{"n"}},
+		}},
+	}
+
+	repositoryInfos = lru.New(repositoryInfoCacheBytes)
+)
+
+// graphqlEndpoint executes GraphQL queries of graphqlSchema, so clients
+// select the fields they need, e.g. the stars of the recommended
+// repositories, which are only fetched from GitHub when selected. Queries
+// are POSTed as JSON, or sent with GET in the query parameter.
+func graphqlEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	var req graphqlRequest
+	switch r.Method {
+	case "GET", "HEAD":
+		// the same cap as POSTed queries, before they are parsed
+		if len(r.URL.RawQuery) > maxGraphQLBody {
+			writeJSON(w, r, http.StatusBadRequest, graphqlErrorResponse(fmt.Sprintf("Query too long, expected at most %d bytes", maxGraphQLBody)))
+			return
+		}
+		req.Query = r.FormValue("query")
+		req.OperationName = r.FormValue("operationName")
+		if req.Query == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(graphqlSchema))
+			return
+		}
+		if variables := r.FormValue("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeJSON(w, r, http.StatusBadRequest, graphqlErrorResponse(fmt.Sprintf("Invalid variables: %v", err)))
+				return
+			}
+		}
+	case "POST":
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody)).Decode(&req); err != nil {
+			writeJSON(w, r, http.StatusBadRequest, graphqlErrorResponse(fmt.Sprintf("Invalid request: %v", err)))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeJSON(w, r, http.StatusMethodNotAllowed, graphqlErrorResponse("Method not allowed"))
+		return
+	}
+
+	query, err := graphql.Parse(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		writeJSON(w, r, http.StatusBadRequest, graphqlResponse{Errors: []*graphql.Error{err.(*graphql.Error)}})
+		return
+	}
+	model, version := modelForRequest(r)
+	if model == nil {
+		writeJSON(w, r, http.StatusInternalServerError, graphqlErrorResponse("model was not initialized"))
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	e := &graphqlExecution{
+		r:                  r,
+		model:              model,
+		version:            version,
+		locale:             requestLocale(r),
+		workers:            make(chan struct{}, graphqlWorkers),
+		gitHubRepositories: map[string]bool{},
+	}
+	data := e.object(nil, query.Selection, graphqlTypes["Query"], e.query)
+	writeJSON(w, r, http.StatusOK, graphqlResponse{Data: data, Errors: e.errors})
+}
+
+func graphqlErrorResponse(message string) graphqlResponse {
+	return graphqlResponse{Errors: []*graphql.Error{{Message: message}}}
+}
+
+// object resolves the selection of an object of type t, whose fields
+// resolve resolves. The fields that fail are null, and their errors are
+// listed in the response.
+func (e *graphqlExecution) object(path []interface{}, selection []*graphql.Field, t graphqlType, resolve func(f *graphql.Field, path []interface{}) (interface{}, error)) graphql.Object {
+	object := make(graphql.Object, 0, len(selection))
+	for _, f := range selection {
+		fieldPath := append(path[:len(path):len(path)], f.ResponseKey())
+		value, err := e.field(f, fieldPath, t, resolve)
+		if err != nil {
+			e.fail(f, fieldPath, err)
+		}
+		object = append(object, graphql.ObjectField{Name: f.ResponseKey(), Value: value})
+	}
+	return object
+}
+
+// field checks a field against its type before resolving it
+func (e *graphqlExecution) field(f *graphql.Field, path []interface{}, t graphqlType, resolve func(f *graphql.Field, path []interface{}) (interface{}, error)) (interface{}, error) {
+	if atomic.AddInt32(&e.fields, 1) > maxGraphQLFields {
+		return nil, fmt.Errorf("Too many fields, expected at most %d", maxGraphQLFields)
+	}
+	if f.Name == "__typename" {
+		return t.name, nil
+	}
+	def, ok := t.fields[f.Name]
+	if !ok {
+		return nil, fmt.Errorf("Cannot query field %q on type %q", f.Name, t.name)
+	}
+	for name := range f.Arguments {
+		if !hasField(def.arguments, name) {
+			return nil, fmt.Errorf("Unknown argument %q of field %q", name, f.Name)
+		}
+	}
+	if def.object && len(f.Selection) == 0 {
+		return nil, fmt.Errorf("Field %q of type %q must have a selection", f.Name, t.name)
+	}
+	if !def.object && len(f.Selection) > 0 {
+		return nil, fmt.Errorf("Field %q must not have a selection", f.Name)
+	}
+	return resolve(f, path)
+}
+
+// fetchFromGitHub counts a repository whose metadata is fetched from
+// GitHub, or fails past maxGraphQLGitHubRepositories of them
+func (e *graphqlExecution) fetchFromGitHub(repo string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.gitHubRepositories[repo] && len(e.gitHubRepositories) >= maxGraphQLGitHubRepositories {
+		return fmt.Errorf("Too many repositories fetched from GitHub, expected at most %d", maxGraphQLGitHubRepositories)
+	}
+	e.gitHubRepositories[repo] = true
+	return nil
+}
+
+func (e *graphqlExecution) fail(f *graphql.Field, path []interface{}, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errors = append(e.errors, &graphql.Error{Message: err.Error(), Locations: []graphql.Location{f.Location}, Path: path})
+}
+
+func (e *graphqlExecution) query(f *graphql.Field, path []interface{}) (interface{}, error) {
+	switch f.Name {
+	case "recommendations":
+		if atomic.AddInt32(&e.recommendationFields, 1) > maxGraphQLRecommendations {
+			return nil, fmt.Errorf("Too many recommendations, expected at most %d", maxGraphQLRecommendations)
+		}
+		return e.recommendations(f, path)
+	case "repository":
+		name, err := stringArgument(f, "name")
+		if err != nil {
+			return nil, err
+		}
+		repo := e.model.Repository(name)
+		if repo == "" {
+			return nil, nil
+		}
+		return e.repository(path, f.Selection, repo), nil
+	}
+	return nil, nil
+}
+
+// recommendations resolves the recommendations of the seed repositories
+// of the repos argument, the public stars of user, or the stars of the
+// signed in user, like the API
+func (e *graphqlExecution) recommendations(f *graphql.Field, path []interface{}) (interface{}, error) {
+	n, err := intArgument(f, "n", numRecommendations)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || n > maxAPIRecommendations {
+		return nil, fmt.Errorf("Invalid n %d, expected 1 to %d", n, maxAPIRecommendations)
+	}
+	repos, err := listArgument(f, "repos", maxSeeds)
+	if err != nil {
+		return nil, err
+	}
+	languages, err := listArgument(f, "lang", maxPatterns)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := listArgument(f, "topic", maxPatterns)
+	if err != nil {
+		return nil, err
+	}
+	login, err := stringArgument(f, "user")
+	if err != nil {
+		return nil, err
+	}
+	opts := []recommender.RecommendOption{recommender.Languages(languages...), recommender.Topics(topics...), recommender.Filters(filters...)}
+
+	var user, subject string
+	var items, unknown []string
+	switch {
+	case len(repos) > 0:
+		items, unknown = knownRepositories(e.model, repos)
+		if len(items) == 0 {
+			return nil, unknownRepositoriesError(unknown)
+		}
+	case login != "":
+		stars, err := publicStarredRepositories(e.r.Context(), login)
+		if err != nil {
+			return nil, err
+		}
+		user, items, subject = login, repositoryNames(stars), "they starred"
+	default:
+		user, err = authenticatedUser(e.r)
+		if err == nil {
+			items, err = starred(e.r, time.Time{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unauthorized: sign in with GitHub or pass seed repositories in repos")
+		}
+		subject = "you starred"
+		opts = append(opts, recommender.ExcludePatterns(excludeCookie(e.r)...))
+	}
+
+	recs, err := e.model.Recommend(e.r.Context(), items, n, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t := graphqlTypes["Recommendations"]
+	return e.object(path, f.Selection, t, func(f *graphql.Field, path []interface{}) (interface{}, error) {
+		switch f.Name {
+		case "user":
+			if user == "" {
+				return nil, nil
+			}
+			return user, nil
+		case "items":
+			return nonNilList(items), nil
+		case "unknown":
+			return nonNilList(unknown), nil
+		case "modelVersion":
+			return e.version, nil
+		case "recommendations":
+			return e.recommendationList(path, f.Selection, recs, subject), nil
+		}
+		return nil, nil
+	}), nil
+}
+
+// recommendationList resolves recommendations concurrently, as they may
+// fetch the metadata of their repositories from GitHub, in at most
+// graphqlWorkers goroutines per query. Items are resolved by the caller
+// while the workers are busy, so nested lists never wait for each other.
+func (e *graphqlExecution) recommendationList(path []interface{}, selection []*graphql.Field, recs []RepositoryScore, subject string) []graphql.Object {
+	list := make([]graphql.Object, len(recs))
+	var wg sync.WaitGroup
+	for i, rec := range recs {
+		itemPath := append(path[:len(path):len(path)], i)
+		rec := graphqlRecommendation{rec, i + 1, subject}
+		select {
+		case e.workers <- struct{}{}:
+			wg.Add(1)
+			go func(i int, rec graphqlRecommendation) {
+				defer func() { <-e.workers }()
+				defer wg.Done()
+				list[i] = e.recommendation(itemPath, selection, rec)
+			}(i, rec)
+		default:
+			list[i] = e.recommendation(itemPath, selection, rec)
+		}
+	}
+	wg.Wait()
+	return list
+}
+
+func (e *graphqlExecution) recommendation(path []interface{}, selection []*graphql.Field, rec graphqlRecommendation) graphql.Object {
+	return e.object(path, selection, graphqlTypes["Recommendation"], func(f *graphql.Field, path []interface{}) (interface{}, error) {
+		switch f.Name {
+		case "rank":
+			return rec.rank, nil
+		case "score":
+			return rec.Score, nil
+		case "formattedScore":
+			return formatDecimal(e.locale, rec.Score, 2), nil
+		case "explanation":
+			if s := explanation(rec.Because, rec.subject); s != "" {
+				return s, nil
+			}
+			return nil, nil
+		case "because":
+			because := make([]graphql.Object, len(rec.Because))
+			for i, repo := range rec.Because {
+				because[i] = e.repository(append(path[:len(path):len(path)], i), f.Selection, repo)
+			}
+			return because, nil
+		case "repository":
+			return e.repository(path, f.Selection, rec.Repository), nil
+		}
+		return nil, nil
+	})
+}
+
+func (e *graphqlExecution) repository(path []interface{}, selection []*graphql.Field, repo string) graphql.Object {
+	return e.object(path, selection, graphqlTypes["Repository"], func(f *graphql.Field, path []interface{}) (interface{}, error) {
+		switch f.Name {
+		case "name":
+			return repo, nil
+		case "id":
+			return strconv.FormatInt(recommender.RepositoryItemID(repo), 10), nil
+		case "url":
+			return recommender.RepositoryURL(repo), nil
+		case "languages":
+			languages, _ := e.model.Labels(repo)
+			return nonNilList(languages), nil
+		case "topics":
+			_, topics := e.model.Labels(repo)
+			return nonNilList(topics), nil
+		case "stars", "description", "formattedStars", "updated":
+			if err := e.fetchFromGitHub(repo); err != nil {
+				return nil, err
+			}
+			info, err := fetchRepositoryInfo(e.r.Context(), repo)
+			if err != nil {
+				return nil, err
+			}
+			switch f.Name {
+			case "stars":
+				return info.Stars, nil
+			case "formattedStars":
+				return formatCount(e.locale, info.Stars), nil
+			case "updated":
+				if info.PushedAt.IsZero() {
+					return nil, nil
+				}
+				return formatRelative(info.PushedAt, time.Now()), nil
+			}
+			return info.Description, nil
+		case "summary":
+			if err := e.fetchFromGitHub(repo); err != nil {
+				return nil, err
+			}
+			return repositorySummary(e.r, repo)
+		case "similar":
+			if atomic.AddInt32(&e.similarFields, 1) > maxGraphQLSimilar {
+				return nil, fmt.Errorf("Too many similar lists, expected at most %d", maxGraphQLSimilar)
+			}
+			n, err := intArgument(f, "n", numSimilar)
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 || n > maxAPIRecommendations {
+				return nil, fmt.Errorf("Invalid n %d, expected 1 to %d", n, maxAPIRecommendations)
+			}
+			similar, err := e.model.Similar(repo, n, recommender.Filters(filters...))
+			if err != nil {
+				return nil, err
+			}
+			return e.recommendationList(path, f.Selection, similar, ""), nil
+		}
+		return nil, nil
+	})
+}
+
+// explanation says why a repository is recommended, as the
+// recommendations page does
+func explanation(because []string, subject string) string {
+	if len(because) == 0 {
+		return ""
+	}
+	if subject == "" {
+		return fmt.Sprintf("Because of %s", strings.Join(because, " and "))
+	}
+	return fmt.Sprintf("Because %s %s", subject, strings.Join(because, " and "))
+}
+
+// fetchRepositoryInfo returns the metadata of a public repository, cached
+// for repositoryInfoMaxAge
+func fetchRepositoryInfo(ctx context.Context, repo string) (*repositoryInfo, error) {
+	if cached, ok := repositoryInfos.Get(repo); ok {
+		if info := cached.(*repositoryInfo); time.Since(info.fetched) < repositoryInfoMaxAge {
+			return info, nil
+		}
+	}
+	req, err := newPublicGitHubRequest(ctx, gitHubAPIURL+fmt.Sprintf(gitHubRepositoryPath, repo), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, errGitHubRateLimited
+		}
+		fallthrough
+	default:
+		return nil, fmt.Errorf("Unexpected status from GitHub: %s", resp.Status)
+	}
+	info := &repositoryInfo{fetched: time.Now()}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, err
+	}
+	repositoryInfos.Add(repo, info, int64(len(repo)+len(info.Description)+64))
+	return info, nil
+}
+
+// describeRepositories fetches the metadata of recommended repositories
+// concurrently, leaving out the ones that fail
+func describeRepositories(r *http.Request, recs []RepositoryScore) map[string]*repositoryInfo {
+	ctx := r.Context()
+	result := make(map[string]*repositoryInfo, len(recs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, rec := range recs {
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			info, err := fetchRepositoryInfo(ctx, repo)
+			if err != nil {
+				logWarningf("Unable to describe %s: %v", repo, err)
+				return
+			}
+			mu.Lock()
+			result[repo] = info
+			mu.Unlock()
+		}(rec.Repository)
+	}
+	wg.Wait()
+	return result
+}
+
+// stringArgument returns a string argument of a field, "" if it is null
+// or missing
+func stringArgument(f *graphql.Field, name string) (string, error) {
+	switch v := f.Arguments[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("Argument %q of field %q must be a String", name, f.Name)
+}
+
+// intArgument returns an int argument of a field, or its default value
+func intArgument(f *graphql.Field, name string, defaultValue int) (int, error) {
+	switch v := f.Arguments[name].(type) {
+	case nil:
+		return defaultValue, nil
+	case int:
+		return v, nil
+	}
+	return 0, fmt.Errorf("Argument %q of field %q must be an Int", name, f.Name)
+}
+
+// listArgument returns a list of strings argument of a field, or an error
+// if there are more than max of them. A single string is a list of one.
+func listArgument(f *graphql.Field, name string, max int) ([]string, error) {
+	var values []string
+	switch v := f.Arguments[name].(type) {
+	case nil:
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("Argument %q of field %q must be a list of String", name, f.Name)
+			}
+			values = append(values, s)
+		}
+	default:
+		return nil, fmt.Errorf("Argument %q of field %q must be a list of String", name, f.Name)
+	}
+	return checkList(name, values, max)
+}
+
+// nonNilList returns an empty list instead of nil, which encodes as null
+func nonNilList(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
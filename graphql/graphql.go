@@ -0,0 +1,640 @@
+// Package graphql parses GraphQL queries, the subset the /graphql endpoint
+// of the server executes: a query operation of fields, with aliases,
+// arguments and variables. Fragments, directives, mutations and
+// subscriptions are not supported.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// Query is a parsed query operation
+	Query struct {
+		Name      string
+		Selection []*Field
+	}
+
+	// Field is a field of a selection, with the values of its arguments,
+	// variables substituted, and its own selection if it is an object
+	Field struct {
+		Alias     string
+		Name      string
+		Arguments map[string]interface{}
+		Selection []*Field
+		Location  Location
+	}
+
+	// Location is the position of a field or error in a query, from 1
+	Location struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	}
+
+	// Error is an error of a query in the format of GraphQL responses:
+	// where in the query it is, and the path of the field that failed
+	Error struct {
+		Message   string        `json:"message"`
+		Locations []Location    `json:"locations,omitempty"`
+		Path      []interface{} `json:"path,omitempty"`
+	}
+
+	// Object is a JSON object that keeps the order of its fields, as
+	// GraphQL responses list fields in the order they were selected
+	Object []ObjectField
+
+	// ObjectField is a field of an Object
+	ObjectField struct {
+		Name  string
+		Value interface{}
+	}
+
+	// typeRef is the type of a variable, as in [String!]
+	typeRef struct {
+		name    string
+		list    *typeRef
+		nonNull bool
+	}
+
+	token struct {
+		kind  tokenKind
+		value string
+		Location
+	}
+
+	tokenKind int
+
+	parser struct {
+		tokens    []token
+		pos       int
+		variables map[string]interface{}
+		depth     int
+	}
+)
+
+// MaxDepth caps how deeply selections, lists and objects nest in a query
+const MaxDepth = 32
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ResponseKey is the name of the field in the response: its alias if it
+// has one
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// MarshalJSON encodes the fields in order
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Parse parses the operation of a query document, the one named
+// operationName if the document has several, with the values of its
+// variables, as decoded from JSON.
+func Parse(document string, operationName string, variables map[string]interface{}) (*Query, error) {
+	tokens, err := lex(document)
+	if err != nil {
+		return nil, err
+	}
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	p := &parser{tokens: tokens}
+	var query *Query
+	var operations int
+	for p.peek().kind != tokenEOF {
+		start := p.pos
+		name, err := p.operationName()
+		if err != nil {
+			return nil, err
+		}
+		operations++
+		if operationName != "" && name != operationName {
+			// skipped, but still parsed so syntax errors are reported
+			p.pos = start
+			if _, err := p.operation(nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if query != nil {
+			return nil, &Error{Message: "Must provide operationName if the query has several operations"}
+		}
+		p.pos = start
+		if query, err = p.operation(variables); err != nil {
+			return nil, err
+		}
+	}
+	if query == nil {
+		if operations == 0 {
+			return nil, &Error{Message: "Must provide an operation"}
+		}
+		return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q", operationName)}
+	}
+	return query, nil
+}
+
+// operationName peeks at the name of the next operation
+func (p *parser) operationName() (string, error) {
+	if p.at("{") {
+		return "", nil
+	}
+	t := p.next()
+	if t.kind != tokenName {
+		return "", p.unexpected(t, "an operation")
+	}
+	if t.value == "fragment" {
+		return "", &Error{Message: "Fragments are not supported", Locations: []Location{t.Location}}
+	}
+	if t.value != "query" {
+		return "", &Error{Message: fmt.Sprintf("Only queries are supported, not %s", t.value), Locations: []Location{t.Location}}
+	}
+	if p.peek().kind == tokenName {
+		return p.next().value, nil
+	}
+	return "", nil
+}
+
+// operation parses an operation, its variables taken from values, or only
+// checks its syntax if values is nil
+func (p *parser) operation(values map[string]interface{}) (*Query, error) {
+	name, _ := p.operationName()
+	p.variables = map[string]interface{}{}
+	if p.at("(") {
+		p.next()
+		for !p.at(")") {
+			if err := p.variableDefinition(values); err != nil {
+				return nil, err
+			}
+		}
+		p.next()
+	}
+	if p.at("@") {
+		return nil, &Error{Message: "Directives are not supported", Locations: []Location{p.peek().Location}}
+	}
+	selection, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Name: name, Selection: selection}, nil
+}
+
+// variableDefinition parses $name: Type = default, and sets the variable
+// to its coerced value
+func (p *parser) variableDefinition(values map[string]interface{}) error {
+	if err := p.expect("$"); err != nil {
+		return err
+	}
+	name := p.next()
+	if name.kind != tokenName {
+		return p.unexpected(name, "a variable name")
+	}
+	if err := p.expect(":"); err != nil {
+		return err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return err
+	}
+	var defaultValue interface{}
+	hasDefault := p.at("=")
+	if hasDefault {
+		p.next()
+		if defaultValue, err = p.value(true); err != nil {
+			return err
+		}
+	}
+	if values == nil {
+		p.variables[name.value] = nil
+		return nil
+	}
+	value, ok := values[name.value]
+	if !ok && hasDefault {
+		value, ok = defaultValue, true
+	}
+	if !ok || value == nil {
+		if typ.nonNull {
+			return &Error{Message: fmt.Sprintf("Variable $%s of type %s is required", name.value, typ), Locations: []Location{name.Location}}
+		}
+		p.variables[name.value] = nil
+		return nil
+	}
+	coerced, err := typ.coerce(value)
+	if err != nil {
+		return &Error{Message: fmt.Sprintf("Variable $%s: %v", name.value, err), Locations: []Location{name.Location}}
+	}
+	p.variables[name.value] = coerced
+	return nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	var typ *typeRef
+	t := p.next()
+	switch {
+	case t.kind == tokenPunctuator && t.value == "[":
+		elem, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		typ = &typeRef{list: elem}
+	case t.kind == tokenName:
+		typ = &typeRef{name: t.value}
+	default:
+		return nil, p.unexpected(t, "a type")
+	}
+	if p.at("!") {
+		p.next()
+		typ.nonNull = true
+	}
+	return typ, nil
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// coerce checks a variable value decoded from JSON, or a default value,
+// against its type, and converts integers to int
+func (t *typeRef) coerce(value interface{}) (interface{}, error) {
+	if value == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return nil, nil
+	}
+	if t.list != nil {
+		values, ok := value.([]interface{})
+		if !ok {
+			// a single value is a list of one
+			values = []interface{}{value}
+		}
+		coerced := make([]interface{}, len(values))
+		for i, v := range values {
+			var err error
+			if coerced[i], err = t.list.coerce(v); err != nil {
+				return nil, err
+			}
+		}
+		return coerced, nil
+	}
+	// default values are parsed, with ints as int
+	if n, ok := value.(int); ok {
+		value = float64(n)
+	}
+	switch t.name {
+	case "Int":
+		if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) <= math.MaxInt32 {
+			return int(f), nil
+		}
+	case "Float":
+		if f, ok := value.(float64); ok {
+			return f, nil
+		}
+	case "String":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return strconv.FormatFloat(v, 'f', -1, 64), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	default:
+		return value, nil
+	}
+	return nil, fmt.Errorf("expected a value of type %s, got %v", t, value)
+}
+
+func (p *parser) selectionSet() ([]*Field, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selection []*Field
+	for !p.at("}") {
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		selection = append(selection, field)
+	}
+	p.next()
+	return selection, nil
+}
+
+func (p *parser) field() (*Field, error) {
+	t := p.next()
+	if t.kind == tokenPunctuator && t.value == "..." {
+		return nil, &Error{Message: "Fragments are not supported", Locations: []Location{t.Location}}
+	}
+	if t.kind != tokenName {
+		return nil, p.unexpected(t, "a field")
+	}
+	field := &Field{Name: t.value, Arguments: map[string]interface{}{}, Location: t.Location}
+	if p.at(":") {
+		p.next()
+		name := p.next()
+		if name.kind != tokenName {
+			return nil, p.unexpected(name, "a field")
+		}
+		field.Alias, field.Name = field.Name, name.value
+	}
+	if p.at("(") {
+		p.next()
+		for !p.at(")") {
+			name := p.next()
+			if name.kind != tokenName {
+				return nil, p.unexpected(name, "an argument")
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.value(false)
+			if err != nil {
+				return nil, err
+			}
+			field.Arguments[name.value] = value
+		}
+		p.next()
+	}
+	if p.at("@") {
+		return nil, &Error{Message: "Directives are not supported", Locations: []Location{p.peek().Location}}
+	}
+	if p.at("{") {
+		var err error
+		if field.Selection, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// value parses a value, a constant one in default values
+func (p *parser) value(constant bool) (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt:
+		n, err := strconv.Atoi(t.value)
+		if err != nil || n > math.MaxInt32 || n < math.MinInt32 {
+			return nil, &Error{Message: fmt.Sprintf("Int cannot represent %s", t.value), Locations: []Location{t.Location}}
+		}
+		return n, nil
+	case tokenFloat:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("Float cannot represent %s", t.value), Locations: []Location{t.Location}}
+		}
+		return f, nil
+	case tokenString:
+		return t.value, nil
+	case tokenName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// enum values are passed on as strings
+		return t.value, nil
+	}
+	switch t.value {
+	case "$":
+		name := p.next()
+		if constant || name.kind != tokenName {
+			return nil, p.unexpected(name, "a value")
+		}
+		value, ok := p.variables[name.value]
+		if !ok {
+			return nil, &Error{Message: fmt.Sprintf("Variable $%s is not defined", name.value), Locations: []Location{name.Location}}
+		}
+		return value, nil
+	case "[":
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		list := []interface{}{}
+		for !p.at("]") {
+			value, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		p.next()
+		return list, nil
+	case "{":
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		object := map[string]interface{}{}
+		for !p.at("}") {
+			name := p.next()
+			if name.kind != tokenName {
+				return nil, p.unexpected(name, "a field")
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			object[name.value] = value
+		}
+		p.next()
+		return object, nil
+	}
+	return nil, p.unexpected(t, "a value")
+}
+
+// nest enters a selection, list or object, or fails past MaxDepth
+func (p *parser) nest() error {
+	if p.depth >= MaxDepth {
+		return &Error{Message: fmt.Sprintf("Query is nested too deeply, expected at most %d levels", MaxDepth), Locations: []Location{p.peek().Location}}
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) unnest() {
+	p.depth--
+}
+
+// at tells whether the next token is the given punctuator
+func (p *parser) at(punctuator string) bool {
+	t := p.tokens[p.pos]
+	return t.kind == tokenPunctuator && t.value == punctuator
+}
+
+// expect skips the given punctuator, or fails if it is not next
+func (p *parser) expect(punctuator string) error {
+	if !p.at(punctuator) {
+		return p.unexpected(p.next(), strconv.Quote(punctuator))
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next returns the next token, and keeps returning the EOF at the end
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	found := strconv.Quote(t.value)
+	if t.kind == tokenEOF {
+		found = "the end of the query"
+	}
+	return &Error{Message: fmt.Sprintf("Syntax error: expected %s, found %s", expected, found), Locations: []Location{t.Location}}
+}
+
+// lex splits a document into tokens, skipping whitespace, commas and
+// comments, and ending with an EOF token
+func lex(document string) ([]token, error) {
+	var tokens []token
+	// the column is counted as the document is read, in runes
+	line, column, counted := 1, 1, 0
+	for i := 0; i < len(document); {
+		c := document[i]
+		column += utf8.RuneCountInString(document[counted:i])
+		counted = i
+		loc := Location{line, column}
+		switch {
+		case c == '\n':
+			i++
+			line, column, counted = line+1, 1, i
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(document[i:], "..."):
+			tokens = append(tokens, token{tokenPunctuator, "...", loc})
+			i += 3
+		case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+			tokens = append(tokens, token{tokenPunctuator, string(c), loc})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(document) && isNameChar(document[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokenName, document[i:j], loc})
+			i = j
+		case c == '-' || c >= '0' && c <= '9':
+			j, kind := i+1, tokenInt
+			for j < len(document) && (isNameChar(document[j]) || document[j] == '.' ||
+				(document[j] == '-' || document[j] == '+') && (document[j-1] == 'e' || document[j-1] == 'E')) {
+				if !(document[j] >= '0' && document[j] <= '9') {
+					kind = tokenFloat
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind, document[i:j], loc})
+			i = j
+		case c == '"':
+			if strings.HasPrefix(document[i:], `"""`) {
+				return nil, &Error{Message: "Block strings are not supported", Locations: []Location{loc}}
+			}
+			j := i + 1
+			for j < len(document) && document[j] != '"' && document[j] != '\n' {
+				if document[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(document) || document[j] != '"' {
+				return nil, &Error{Message: "Syntax error: unterminated string", Locations: []Location{loc}}
+			}
+			// GraphQL strings escape like JSON ones
+			var s string
+			if err := json.Unmarshal([]byte(document[i:j+1]), &s); err != nil {
+				return nil, &Error{Message: "Syntax error: invalid string", Locations: []Location{loc}}
+			}
+			tokens = append(tokens, token{tokenString, s, loc})
+			i = j + 1
+		default:
+			r, _ := utf8.DecodeRuneInString(document[i:])
+			return nil, &Error{Message: fmt.Sprintf("Syntax error: unexpected character %q", r), Locations: []Location{loc}}
+		}
+	}
+	loc := Location{line, column + utf8.RuneCountInString(document[counted:])}
+	return append(tokens, token{tokenEOF, "", loc}), nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,118 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	query, err := Parse(`
+		# recommendations for Go
+		query Recs($repos: [String!]!, $n: Int = 5) {
+			recs: recommendations(repos: $repos, n: $n, lang: ["go", "rust"], topic: "cli,tui") {
+				recommendations { rank repository { name } }
+			}
+		}`, "", map[string]interface{}{"repos": "golang/go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "Recs" || len(query.Selection) != 1 {
+		t.Fatalf("Wrong query: %+v", query)
+	}
+	f := query.Selection[0]
+	if f.Alias != "recs" || f.Name != "recommendations" || f.ResponseKey() != "recs" || f.Location != (Location{4, 4}) {
+		t.Errorf("Wrong field: %+v", f)
+	}
+	expected := map[string]interface{}{
+		"repos": []interface{}{"golang/go"},
+		"n":     5,
+		"lang":  []interface{}{"go", "rust"},
+		"topic": "cli,tui",
+	}
+	if !reflect.DeepEqual(f.Arguments, expected) {
+		t.Errorf("Wrong arguments: %#v", f.Arguments)
+	}
+	recs := f.Selection[0]
+	if recs.Name != "recommendations" || len(recs.Selection) != 2 || recs.Selection[1].Selection[0].Name != "name" {
+		t.Errorf("Wrong selection: %+v", recs)
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	var variables map[string]interface{}
+	json.Unmarshal([]byte(`{"n": 3, "score": 0.5, "id": 42}`), &variables)
+	query, err := Parse(`query($n: Int!, $score: Float, $id: ID, $missing: String) { f(n: $n, score: $score, id: $id, missing: $missing) }`, "", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"n": 3, "score": 0.5, "id": "42", "missing": nil}
+	if args := query.Selection[0].Arguments; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Wrong arguments: %#v", args)
+	}
+}
+
+func TestParseOperationName(t *testing.T) {
+	document := `query A { a } query B { b }`
+	query, err := Parse(document, "B", nil)
+	if err != nil || query.Selection[0].Name != "b" {
+		t.Errorf("Wrong operation: %+v %v", query, err)
+	}
+	if _, err := Parse(document, "", nil); err == nil {
+		t.Errorf("Expected an error without an operation name")
+	}
+	if _, err := Parse(document, "C", nil); err == nil {
+		t.Errorf("Expected an error for an unknown operation")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for document, message := range map[string]string{
+		``:                                  "Must provide an operation",
+		`{ a `:                              "expected a field, found the end of the query",
+		`{ a(n: ) }`:                        `expected a value, found ")"`,
+		`{ a(n: $n) }`:                      "Variable $n is not defined",
+		`query($n: Int!) { a(n: $n) }`:      "Variable $n of type Int! is required",
+		`mutation { star(repo: "a/b") }`:    "Only queries are supported",
+		`{ ... on Query { a } }`:            "Fragments are not supported",
+		`{ a @skip(if: true) }`:             "Directives are not supported",
+		`{ a(s: "unterminated) }`:           "unterminated string",
+		`{ a(n: 99999999999) }`:             "Int cannot represent",
+		`{ a(s: ")") `:                      "expected a field",
+		`{ a ^ }`:                           "unexpected character '^'",
+		`query($n: Int) { a(n: $n) } { b }`: "Must provide operationName",
+	} {
+		_, err := Parse(document, "", nil)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected %q for %q, got %v", message, document, err)
+		}
+	}
+	_, err := Parse(strings.Repeat("{ a ", MaxDepth+1)+strings.Repeat("}", MaxDepth+1), "", nil)
+	if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("Expected an error for a deep query, got %v", err)
+	}
+	_, err = Parse(`{ a(n: `+strings.Repeat("[", MaxDepth+1)+`) }`, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("Expected an error for a deep list, got %v", err)
+	}
+	_, err = Parse("{ a(s: \"é\")\n  b(s: \"é\") ^ }", "", nil)
+	if e, ok := err.(*Error); !ok || len(e.Locations) != 1 || e.Locations[0] != (Location{2, 13}) {
+		t.Errorf("Wrong location of an unexpected character: %v", err)
+	}
+
+	_, err = Parse(`query($n: Int) { a(n: $n) }`, "", map[string]interface{}{"n": 1.5})
+	if err == nil || !strings.Contains(err.Error(), "expected a value of type Int") {
+		t.Errorf("Expected an error for a Float Int, got %v", err)
+	}
+}
+
+func TestObjectMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Object{{"z", 1}, {"a", Object{{"y", []string{"x"}}}}, {"m", nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"z":1,"a":{"y":["x"]},"m":null}` {
+		t.Errorf("Wrong JSON: %s", b)
+	}
+}
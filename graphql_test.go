@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphQL(t *testing.T) {
+	server, client, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+
+	post := func(query string, variables map[string]interface{}) (int, []byte) {
+		body, _ := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+		resp, err := client.Post(server.URL+"/graphql", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	code, body := post(`query Recs($repos: [String!]) {
+		recs: recommendations(repos: $repos, n: 3) {
+			__typename
+			unknown
+			recommendations {
+				rank
+				explanation
+				repository { name stars description }
+			}
+		}
+	}`, map[string]interface{}{"repos": []string{"tensorflow/tensorflow", "BVLC/caffe", "not/there"}})
+	if code != http.StatusOK {
+		t.Fatalf("Failed: %d %s", code, body)
+	}
+	// fields are in the order they were selected
+	if !strings.HasPrefix(string(body), `{"data":{"recs":{"__typename":"Recommendations","unknown":["not/there"],"recommendations":[{"rank":1,"explanation":"Because of `) {
+		t.Errorf("Wrong response: %s", body)
+	}
+	var response struct {
+		Data struct {
+			Recs struct {
+				Recommendations []struct {
+					Repository struct {
+						Name        string
+						Stars       *int
+						Description string
+					}
+				}
+			}
+		}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	recs := response.Data.Recs.Recommendations
+	if len(recs) != 3 || recs[0].Repository.Stars == nil || !strings.Contains(recs[0].Repository.Description, "fake repository") || response.Errors != nil {
+		t.Errorf("Wrong response: %s", body)
+	}
+
+	// failed fields are null, with their errors
+	code, body = post(`{ repository(name: "golang/go") { name license similar(n: 2) { rank } } missing: repository(name: "not/there") { name } }`, nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"license":null,"similar":[{"rank":1},{"rank":2}]}`) ||
+		!strings.Contains(string(body), `"missing":null`) ||
+		!strings.Contains(string(body), `"errors":[{"message":"Cannot query field \"license\" on type \"Repository\"","locations":[{"line":1,"column":40}],"path":["repository","license"]}]`) {
+		t.Errorf("Wrong response: %d %s", code, body)
+	}
+
+	code, body = post(`{ repository(name: "golang/go") { formattedStars updated similar(n: 1) { formattedScore } } }`, nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"formattedStars":"`) || !strings.Contains(string(body), `"updated":"1 week ago","similar":[{"formattedScore":"0.`) {
+		t.Errorf("Wrong formatted response: %d %s", code, body)
+	}
+
+	var aliases strings.Builder
+	for i := 0; i <= maxGraphQLRecommendations; i++ {
+		fmt.Fprintf(&aliases, `r%d: recommendations(repos: ["golang/go"], n: 1) { items } `, i)
+	}
+	code, body = post("{ "+aliases.String()+"}", nil)
+	if code != http.StatusOK || strings.Count(string(body), `"items":["golang/go"]`) != maxGraphQLRecommendations || !strings.Contains(string(body), "Too many recommendations") {
+		t.Errorf("Recommendations were not capped: %d %.200s", code, body)
+	}
+
+	aliases.Reset()
+	for i := 0; i <= maxGraphQLSimilar; i++ {
+		fmt.Fprintf(&aliases, `s%d: similar(n: 1) { rank } `, i)
+	}
+	code, body = post(`{ repository(name: "golang/go") { `+aliases.String()+`} }`, nil)
+	if code != http.StatusOK || strings.Count(string(body), `[{"rank":1}]`) != maxGraphQLSimilar || !strings.Contains(string(body), "Too many similar lists") {
+		t.Errorf("Similar lists were not capped: %d %.200s", code, body)
+	}
+
+	code, body = post(`{ a: repository(name: "golang/go") { stars } repository(name: "golang/go") { similar(n: 100) { repository { name stars description } } } }`, nil)
+	if code != http.StatusOK || strings.Count(string(body), "Too many repositories fetched from GitHub") != 2 {
+		t.Errorf("Repositories fetched from GitHub were not capped: %d %.200s", code, body)
+	}
+
+	code, body = post(`{ recommendations { items } }`, nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"data":{"recommendations":null}`) || !strings.Contains(string(body), "Unauthorized") {
+		t.Errorf("Wrong response without seeds: %d %s", code, body)
+	}
+	signIn(t, server, client, "ml-researcher")
+	code, body = post(`{ recommendations(n: 1) { user recommendations { explanation } } }`, nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"user":"ml-researcher","recommendations":[{"explanation":"Because you starred `) {
+		t.Errorf("Wrong response for a signed in user: %d %s", code, body)
+	}
+
+	if code, body = post(`{ recommendations(`, nil); code != http.StatusBadRequest || !strings.Contains(string(body), "Syntax error") {
+		t.Errorf("Wrong response to a syntax error: %d %s", code, body)
+	}
+
+	resp, err := client.Get(server.URL + "/graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(string(schema), "type Query {") {
+		t.Errorf("Wrong schema: %s", schema)
+	}
+
+	resp, err = client.Get(server.URL + "/graphql?query=" + strings.Repeat("a", maxGraphQLBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Wrong status for a long GET query: %d", resp.StatusCode)
+	}
+}
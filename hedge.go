@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type (
+	hedgedResult struct {
+		attempt int
+		resp    *http.Response
+		err     error
+	}
+
+	// cancelOnClose releases the context of a request once its response
+	// body has been read
+	cancelOnClose struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+)
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// hedgedDo sends a request without a body and, if it has not completed
+// after delay, a duplicate of it. The first response wins and the other
+// attempt is cancelled. If the first attempt fails before the delay the
+// duplicate is sent right away. An error is returned only if both
+// attempts fail.
+func hedgedDo(ctx context.Context, client *http.Client, req *http.Request, delay time.Duration) (*http.Response, error) {
+	results := make(chan hedgedResult, 2)
+	var cancels []context.CancelFunc
+	send := func() {
+		attempt := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := client.Do(req.WithContext(attemptCtx))
+			results <- hedgedResult{attempt, resp, err}
+		}()
+	}
+
+	send()
+	pending := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < 2 {
+				send()
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.attempt]()
+				if len(cancels) < 2 {
+					send()
+					pending++
+				}
+				if pending == 0 {
+					return nil, result.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+			go discardHedgedResults(results, pending)
+			result.resp.Body = cancelOnClose{result.resp.Body, cancels[result.attempt]}
+			return result.resp, nil
+		}
+	}
+}
+
+// discardHedgedResults closes the responses of the attempts that lost the
+// race, in case they completed before being cancelled.
+func discardHedgedResults(results chan hedgedResult, n int) {
+	for i := 0; i < n; i++ {
+		if result := <-results; result.err == nil {
+			result.resp.Body.Close()
+		}
+	}
+}
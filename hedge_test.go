@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedDoReturnsFastestResponse(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			w.Write([]byte("slow"))
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	resp, err := hedgedDo(context.Background(), server.Client(), req, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "fast" {
+		t.Errorf("Wrong response: %q", body)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Hedged request did not cut the latency: %v", time.Since(start))
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestHedgedDoSingleRequestWhenFast(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := hedgedDo(context.Background(), server.Client(), req, time.Second)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+}
+
+func TestHedgedDoFailsWhenBothAttemptsFail(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:1", nil)
+	if _, err := hedgedDo(context.Background(), http.DefaultClient, req, time.Millisecond); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
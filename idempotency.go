@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jbochi/github-recs/lru"
+)
+
+const (
+	// idempotencyKeyTTL is how long the response to a request with an
+	// Idempotency-Key is replayed to its retries
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyCacheBytes bounds the responses kept; the least
+	// recently used go first
+	idempotencyCacheBytes = 32 << 20
+	// idempotentResponseSize approximates the overhead of a response in
+	// the cache, besides its body
+	idempotentResponseSize = 256
+	maxIdempotencyKey      = 255
+)
+
+type (
+	// idempotencyCache keeps the responses to requests with an
+	// Idempotency-Key, by user, path and key
+	idempotencyCache struct {
+		sync.Mutex
+		responses *lru.Cache
+	}
+
+	idempotentResponse struct {
+		done    bool
+		expires time.Time
+		status  int
+		header  http.Header
+		body    []byte
+	}
+
+	// responseRecorder writes a response through while keeping a copy
+	responseRecorder struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+var idempotentResponses = newIdempotencyCache(idempotencyCacheBytes)
+
+func newIdempotencyCache(maxBytes int64) *idempotencyCache {
+	return &idempotencyCache{responses: lru.New(maxBytes)}
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// withIdempotency makes retries of a POST with the same Idempotency-Key
+// header replay the response to the first request instead of acting
+// again, so flaky networks do not duplicate actions. Keys are scoped to
+// the user and the path, so requests of signed out visitors, which
+// nothing tells apart, always act. A retry sent while the first request is
+// still running gets a 409, and server errors are not kept, so they can be
+// retried. Cookies are never replayed: they were set from the cookies of
+// the first request, which may be outdated by the time of the retry.
+func withIdempotency(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		user := tokenDigest(r)
+		if key == "" || r.Method != "POST" || user == "" {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			httpError(w, r, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		key = user + " " + r.URL.Path + " " + key
+
+		cached, first := idempotentResponses.start(key, time.Now())
+		if !first {
+			if !cached.done {
+				httpError(w, r, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+				return
+			}
+			for name, values := range cached.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(cached.status)
+			w.Write(cached.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			if rec.status == 0 || rec.status >= 500 {
+				idempotentResponses.forget(key)
+				return
+			}
+			idempotentResponses.finish(key, rec.status, w.Header(), rec.body.Bytes())
+		}()
+		h(rec, r)
+	}
+}
+
+// start returns a copy of the response kept for key, or reserves key for
+// the caller if there is none, in which case first is true.
+func (c *idempotencyCache) start(key string, now time.Time) (response idempotentResponse, first bool) {
+	c.Lock()
+	defer c.Unlock()
+	if value, ok := c.responses.Get(key); ok {
+		if response := value.(*idempotentResponse); !response.done || now.Before(response.expires) {
+			return *response, false
+		}
+	}
+	c.responses.Add(key, &idempotentResponse{expires: now.Add(idempotencyKeyTTL)}, idempotentResponseSize)
+	return idempotentResponse{}, true
+}
+
+func (c *idempotencyCache) finish(key string, status int, header http.Header, body []byte) {
+	c.Lock()
+	defer c.Unlock()
+	if value, ok := c.responses.Get(key); ok {
+		response := *value.(*idempotentResponse)
+		response.done = true
+		response.status = status
+		response.header = cloneHeader(header)
+		response.header.Del("Set-Cookie")
+		response.body = append([]byte(nil), body...)
+		// added again, as its size changes
+		c.responses.Add(key, &response, int64(len(body))+idempotentResponseSize)
+	}
+}
+
+func (c *idempotencyCache) forget(key string) {
+	c.Lock()
+	defer c.Unlock()
+	c.responses.Remove(key)
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
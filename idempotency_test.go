@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithIdempotency(t *testing.T) {
+	defer func(saved *idempotencyCache) { idempotentResponses = saved }(idempotentResponses)
+	idempotentResponses = newIdempotencyCache(idempotencyCacheBytes)
+
+	calls := 0
+	status := http.StatusCreated
+	handler := withIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", "first")
+		http.SetCookie(w, &http.Cookie{Name: "exclude", Value: "golang/go"})
+		w.WriteHeader(status)
+		w.Write([]byte("done"))
+	})
+	send := func(key, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/star", nil)
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		r.AddCookie(&http.Cookie{Name: "token", Value: token})
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	send("a", "alice")
+	w := send("a", "alice")
+	if calls != 1 || w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Call") != "first" {
+		t.Errorf("Retry was not replayed: %d calls, %d %s", calls, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Replay is not marked")
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Cookies were replayed: %q", w.Header().Get("Set-Cookie"))
+	}
+	send("a", "bob")
+	send("b", "alice")
+	send("", "alice")
+	send("", "alice")
+	if calls != 5 {
+		t.Errorf("Different keys, users or no key must not be replayed: %d calls", calls)
+	}
+	send("a", "")
+	if w := send("a", ""); calls != 7 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("Requests of signed out visitors must not be replayed: %d calls", calls)
+	}
+
+	status = http.StatusBadGateway
+	send("c", "alice")
+	send("c", "alice")
+	if calls != 9 {
+		t.Errorf("Server errors must not be replayed: %d calls", calls)
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	c := newIdempotencyCache(idempotencyCacheBytes)
+	now := time.Now()
+	if _, first := c.start("k", now); !first {
+		t.Fatalf("New key was not reserved")
+	}
+	if response, first := c.start("k", now); first || response.done {
+		t.Errorf("Key in progress was reserved again")
+	}
+	c.finish("k", http.StatusNoContent, http.Header{}, nil)
+	if response, first := c.start("k", now); first || !response.done || response.status != http.StatusNoContent {
+		t.Errorf("Finished response was not returned")
+	}
+	if _, first := c.start("k", now.Add(idempotencyKeyTTL+time.Second)); !first {
+		t.Errorf("Expired key was not reserved again")
+	}
+	if c.responses.Len() != 1 {
+		t.Errorf("Expired responses were not replaced: %d", c.responses.Len())
+	}
+
+	small := newIdempotencyCache(3 * (idempotentResponseSize + 1))
+	for _, key := range []string{"a", "b", "c", "d"} {
+		small.start(key, now)
+	}
+	if _, first := small.start("a", now); !first {
+		t.Errorf("Least recently used response was not evicted")
+	}
+	if s := small.responses.Stats(); s.Evictions != 2 || s.Bytes > s.MaxBytes {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// Hard caps on the inputs of a request, checked before any work is done,
+// so that no request, by mistake or on purpose, can keep a server busy for
+// long. The n parameter is capped by maxAPIRecommendations, and batches by
+// maxBatchRequests.
+const (
+	// maxSeeds caps the seed repositories of a request
+	maxSeeds = 1000
+	// maxPatterns caps the languages, topics and exclude patterns of a
+	// request
+	maxPatterns = 100
+	// maxExcludeCookie caps the value of the exclude cookie, in bytes,
+	// below the 4 KB browsers keep for a cookie with its attributes
+	maxExcludeCookie = 3500
+	// maxStarPageLimit caps STAR_PAGE_LIMIT, so that at most 10000 stars
+	// of a user are fetched and recommended from
+	maxStarPageLimit = 100
+)
+
+// formList returns the comma separated values of a form parameter, which
+// may be repeated, or an error if there are more than max of them
+func formList(r *http.Request, name string, max int) ([]string, error) {
+	if r.Form == nil {
+		r.ParseForm()
+	}
+	return checkList(name, splitPatterns(strings.Join(r.Form[name], ",")), max)
+}
+
+// checkList returns the values of a list parameter, or an error if there
+// are more than max of them
+func checkList(name string, values []string, max int) ([]string, error) {
+	if len(values) > max {
+		return nil, fmt.Errorf("Too many %s: %d, expected at most %d", name, len(values), max)
+	}
+	return values, nil
+}
+
+// formLabels returns the options of the lang and topic parameters, or an
+// error if there are too many of them
+func formLabels(r *http.Request) ([]recommender.RecommendOption, error) {
+	languages, err := formList(r, "lang", maxPatterns)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := formList(r, "topic", maxPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return []recommender.RecommendOption{recommender.Languages(languages...), recommender.Topics(topics...)}, nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// repeatList returns a comma separated list of n distinct values
+func repeatList(format string, n int) string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = fmt.Sprintf(format, i)
+	}
+	return strings.Join(values, ",")
+}
+
+func TestInputCaps(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		status int
+	}{
+		{"/api/v1/recommendations?repos=BVLC/caffe," + repeatList("owner/repo%d", maxSeeds-1), http.StatusOK},
+		{"/api/v1/recommendations?repos=" + repeatList("owner/repo%d", maxSeeds+1), http.StatusBadRequest},
+		{"/api/v1/recommendations?repos=BVLC/caffe&lang=" + repeatList("lang%d", maxPatterns+1), http.StatusBadRequest},
+		{"/api/v1/recommendations?repos=BVLC/caffe&topic=" + repeatList("topic%d", maxPatterns+1), http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		apiRecommendations(w, httptest.NewRequest("GET", test.url, nil))
+		if w.Code != test.status {
+			t.Errorf("Wrong status for %.80s...: %d %s", test.url, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"requests": [{"repos": ["%s"]}]}`, strings.Replace(repeatList("owner/repo%d", maxSeeds+1), ",", `","`, -1))
+	apiBatchRecommendations(w, httptest.NewRequest("POST", "/api/v1/recommendations:batch", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Too many repos") {
+		t.Errorf("Wrong response to too many seeds in a batch: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/?exclude="+repeatList("owner%d", maxPatterns+1), nil)
+	if _, err := excludeSetting(w, r); err == nil || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Saved too many exclude patterns: %v %q", err, w.Header().Get("Set-Cookie"))
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	warmingUpMessage   = "We are warming up. Please come back in a few seconds."
+	unavailableMessage = "Recommendations are unavailable right now. Please come back later."
+	// warmingUpRetryAfter is the Retry-After, in seconds, of responses
+	// served while the models load
+	warmingUpRetryAfter = 10
+	// maxModelLoadShards caps MODEL_LOAD_SHARDS, as each shard builds a
+	// model of the repositories read so far
+	maxModelLoadShards = 100
+)
+
+var (
+	// modelLoad is the state of the load of the models at startup, which
+	// runs in the background so the server answers, and reports its
+	// progress, right away
+	modelLoad struct {
+		sync.Mutex
+		loading bool
+		err     error
+		// the share of the repositories of all the versions read, when
+		// they load in shards, and whether a model of the repositories
+		// read so far is served
+		progress float64
+		partial  bool
+	}
+	// modelLoadShards is how many shards the models load in at startup,
+	// set with MODEL_LOAD_SHARDS, 0 to load them in one go
+	modelLoadShards int
+	// modelServePartial serves the repositories of the shards loaded so
+	// far while the others load, set with MODEL_SERVE_PARTIAL
+	modelServePartial bool
+	// modelsLoaded is closed once the models were loaded, or failed to
+	modelsLoaded = make(chan struct{})
+)
+
+// loadModels loads the model of each version. It stops at the first that
+// fails to load, and keeps its error for /status.
+func loadModels() {
+	modelLoad.Lock()
+	modelLoad.loading = true
+	modelLoad.Unlock()
+
+	var err error
+	for i, v := range modelVersions {
+		var m *recommender.Model
+		m, err = loadStartupModel(v, i)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", v.name, err)
+			break
+		}
+		setVersionModel(v.name, m)
+	}
+	if err != nil {
+		logErrorf("Failed to create vector model %s", err)
+	}
+
+	modelLoad.Lock()
+	modelLoad.loading = false
+	modelLoad.err = err
+	modelLoad.partial = modelLoad.partial && err != nil
+	modelLoad.Unlock()
+	close(modelsLoaded)
+}
+
+// loadStartupModel loads the model of the i-th version at startup, in
+// modelLoadShards shards if set. It reports the progress of each shard
+// and, with modelServePartial, serves the repositories read so far. The
+// shards are in the order of the model files, which should then list the
+// repositories by popularity. Memory mapped models load in one go, as
+// their factors are only read as they are used.
+func loadStartupModel(v modelVersion, i int) (*recommender.Model, error) {
+	path := modelVersionPath(v.name)
+	if modelLoadShards == 0 || modelMapped {
+		return loadModel(path)
+	}
+	m, err := recommender.ReadModelInShards(path, modelMemoryBudget, modelLoadShards, func(partial *recommender.Model, read, total int) {
+		modelLoad.Lock()
+		modelLoad.progress = (float64(i) + float64(read)/float64(total)) / float64(len(modelVersions))
+		modelLoad.Unlock()
+		if !modelServePartial {
+			return
+		}
+		if modelQuantized {
+			partial = partial.Quantized()
+		}
+		logWarningf("Serving the first %d of %d repositories of the model %s", read, total, v.name)
+		setVersionModel(v.name, partial)
+		modelLoad.Lock()
+		modelLoad.partial = true
+		modelLoad.Unlock()
+	})
+	if err == nil && modelQuantized {
+		m = m.Quantized()
+	}
+	return m, err
+}
+
+// modelUnavailable returns the message served instead of the pages while
+// there is no model, and whether it is still loading. It returns "" once
+// there is one, which may have been reloaded after a failed load.
+func modelUnavailable() (message string, loading bool) {
+	if currentModel() != nil {
+		return "", false
+	}
+	modelLoad.Lock()
+	defer modelLoad.Unlock()
+	if modelLoad.loading {
+		return warmingUpMessage, true
+	}
+	return unavailableMessage, false
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeWithoutModel(t *testing.T) {
+	defer setModel(currentModel())
+	setModel(nil)
+	setLoad := func(loading bool, err error) {
+		modelLoad.Lock()
+		defer modelLoad.Unlock()
+		modelLoad.loading, modelLoad.err = loading, err
+	}
+	defer setLoad(false, nil)
+	handler := withMaintenance(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	setLoad(true, nil)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "10" || !strings.Contains(w.Body.String(), "warming up") {
+		t.Errorf("Wrong response while loading: %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	status(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"loading":true`) {
+		t.Errorf("Status does not report loading: %d %s", w.Code, w.Body.String())
+	}
+
+	setLoad(false, errors.New("default: Unable to read data"))
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/api/v1/recommendations", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "unavailable") {
+		t.Errorf("Wrong response after a failed load: %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	status(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"load_error":"default: Unable to read data"`) {
+		t.Errorf("Status does not report the load error: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoadStartupModelInShards(t *testing.T) {
+	defer setModel(currentModel())
+	defer func(shards int, partial bool) {
+		modelLoadShards, modelServePartial = shards, partial
+		modelLoad.Lock()
+		modelLoad.loading, modelLoad.progress, modelLoad.partial = false, 0, false
+		modelLoad.Unlock()
+	}(modelLoadShards, modelServePartial)
+	modelLoadShards, modelServePartial = 4, true
+	modelLoad.Lock()
+	modelLoad.loading = true
+	modelLoad.Unlock()
+
+	m, err := loadStartupModel(modelVersions[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := currentModel()
+	if partial == nil || partial.NumRepositories() != m.NumRepositories()*3/4 {
+		t.Errorf("Expected the first 3 of 4 shards to be served, got %v", partial)
+	}
+	w := httptest.NewRecorder()
+	status(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"load_progress":0.75`) || !strings.Contains(w.Body.String(), `"partial":true`) {
+		t.Errorf("Status does not report the progress: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,133 @@
+// Package lru is a least recently used cache bounded by the size of its
+// entries, shared by the caches of the server so none of them grows with
+// the number of users or repositories seen since it started.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+type (
+	// Cache keeps values by key up to a total size, evicting the least
+	// recently used entries first. Its methods are safe for concurrent
+	// use, and do nothing on a nil Cache, which never has an entry.
+	Cache struct {
+		mu       sync.Mutex
+		maxBytes int64
+		bytes    int64
+		entries  map[string]*list.Element
+		// most recently used first
+		order                   *list.List
+		hits, misses, evictions int64
+	}
+
+	// Stats are the counters of a cache, e.g. to export as metrics
+	Stats struct {
+		Entries   int   `json:"entries"`
+		Bytes     int64 `json:"bytes"`
+		MaxBytes  int64 `json:"max_bytes"`
+		Hits      int64 `json:"hits"`
+		Misses    int64 `json:"misses"`
+		Evictions int64 `json:"evictions"`
+	}
+
+	entry struct {
+		key   string
+		value interface{}
+		size  int64
+	}
+)
+
+// New returns a cache of at most maxBytes. The size of an entry is the
+// one given to Add, which callers estimate, plus its key.
+func New(maxBytes int64) *Cache {
+	return &Cache{maxBytes: maxBytes, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Get returns the value of key, and marks it as the most recently used
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*entry).value, true
+}
+
+// Add sets the value of key, evicting the least recently used entries
+// until the cache fits in its size. A value larger than the whole cache
+// is not kept.
+func (c *Cache) Add(key string, value interface{}, size int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size += int64(len(key))
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	if size > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry{key, value, size})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Remove drops the entry of key, if any
+func (c *Cache) Remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Len returns the number of entries
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns the size of the cache and how it has been used so far
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// remove must be called with the lock held
+func (c *Cache) remove(e *list.Element) {
+	ent := c.order.Remove(e).(*entry)
+	delete(c.entries, ent.key)
+	c.bytes -= ent.size
+}
@@ -0,0 +1,81 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(30)
+	c.Add("a", 1, 9)
+	c.Add("b", 2, 9)
+	c.Add("c", 3, 9)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Entry was evicted before the cache was full")
+	}
+	c.Add("d", 4, 9)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Entry %s was evicted", key)
+		}
+	}
+	want := Stats{Entries: 3, Bytes: 30, MaxBytes: 30, Hits: 4, Misses: 1, Evictions: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats: %+v, expected %+v", got, want)
+	}
+}
+
+func TestCacheReplaceAndRemove(t *testing.T) {
+	c := New(100)
+	c.Add("a", 1, 10)
+	c.Add("a", 2, 20)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Replaced value: %v %v", v, ok)
+	}
+	if s := c.Stats(); s.Entries != 1 || s.Bytes != 21 {
+		t.Errorf("Replaced entry is counted twice: %+v", s)
+	}
+	c.Remove("a")
+	if s := c.Stats(); s.Entries != 0 || s.Bytes != 0 || s.Evictions != 0 {
+		t.Errorf("Removed entry: %+v", s)
+	}
+
+	c.Add("huge", 1, 1000)
+	if c.Len() != 0 {
+		t.Errorf("Entry larger than the cache was kept")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	c.Add("a", 1, 1)
+	if _, ok := c.Get("a"); ok || c.Len() != 0 || c.Stats() != (Stats{}) {
+		t.Errorf("Nil cache has entries")
+	}
+	c.Remove("a")
+}
+
+func TestCacheConcurrency(t *testing.T) {
+	c := New(1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprint(j % 200)
+				if _, ok := c.Get(key); !ok {
+					c.Add(key, i, 10)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Bytes > s.MaxBytes || s.Hits+s.Misses != 8000 {
+		t.Errorf("Inconsistent stats: %+v", s)
+	}
+}
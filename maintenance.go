@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaintenanceMessage = "We are doing some maintenance. Please come back in a few minutes."
+	// maintenanceRetryAfter is the Retry-After, in seconds, of responses
+	// served during maintenance
+	maintenanceRetryAfter = 300
+)
+
+// maintenanceMessage is shown instead of every page while it is set, with
+// MAINTENANCE. "true" or "1" show a default message; anything else is the
+// message itself.
+var maintenanceMessage string
+
+func parseMaintenance(value string) string {
+	value = strings.TrimSpace(value)
+	if on, err := strconv.ParseBool(value); err == nil {
+		if on {
+			return defaultMaintenanceMessage
+		}
+		return ""
+	}
+	return value
+}
+
+// withMaintenance serves h, or a 503 with Retry-After during maintenance
+// or while there is no model: a friendly page for browsers and a JSON
+// error for API clients.
+func withMaintenance(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, retryAfter := maintenanceMessage, maintenanceRetryAfter
+		if message == "" {
+			var loading bool
+			message, loading = modelUnavailable()
+			if loading {
+				retryAfter = warmingUpRetryAfter
+			}
+		}
+		if message == "" {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			w.Header().Set("Cache-Control", privateCacheControl)
+			apiError(w, r, message, http.StatusServiceUnavailable)
+			return
+		}
+		renderError(w, r, http.StatusServiceUnavailable, message)
+	}
+}
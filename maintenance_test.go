@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseMaintenance(t *testing.T) {
+	for value, want := range map[string]string{
+		"":                "",
+		"false":           "",
+		"0":               "",
+		"true":            defaultMaintenanceMessage,
+		"1":               defaultMaintenanceMessage,
+		" Back at noon. ": "Back at noon.",
+	} {
+		if got := parseMaintenance(value); got != want {
+			t.Errorf("parseMaintenance(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestWithMaintenance(t *testing.T) {
+	defer func(saved string) { maintenanceMessage = saved }(maintenanceMessage)
+	handler := withMaintenance(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	maintenanceMessage = ""
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Handler was not served: %d %s", w.Code, w.Body.String())
+	}
+
+	maintenanceMessage = "Back at noon."
+	for _, path := range []string{"/", "/api/v1/recommendations"} {
+		w = httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Errorf("Wrong response for %s during maintenance: %d %v", path, w.Code, w.Header())
+		}
+		if !strings.Contains(w.Body.String(), "Back at noon.") {
+			t.Errorf("Maintenance message is missing for %s: %s", path, w.Body.String())
+		}
+	}
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("API error is not JSON: %s", w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	status(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance":"Back at noon."`) {
+		t.Errorf("Status does not report maintenance: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// wantsJSON tells whether the client prefers JSON over HTML, which lets
+// scripts use the same routes as the browser. The media type with the
+// highest quality wins, and the first one listed on ties.
+func wantsJSON(r *http.Request) bool {
+	best, bestQuality := "", 0.0
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json", "text/html", "application/xhtml+xml":
+			if quality > bestQuality {
+				best, bestQuality = mediaType, quality
+			}
+		}
+	}
+	return best == "application/json"
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logWarningf("Failed to write JSON response: %v", err)
+	}
+}
+
+// httpError replies with an error message in the format the client asked
+// for.
+func httpError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if wantsJSON(r) {
+		writeJSON(w, r, status, errorResponse{message})
+		return
+	}
+	http.Error(w, message, status)
+}
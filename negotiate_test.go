@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/xml, application/json", true},
+		{"*/*", false},
+		{"text/html;q=0.1, application/json", true},
+		{"application/json;q=0.5, text/html;q=0.9", false},
+		{"application/json;q=0", false},
+		{"text/html, application/json", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := wantsJSON(r); got != tt.want {
+			t.Errorf("wantsJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestSignedOutJSONIsPrivate(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	home(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Cache-Control") != privateCacheControl {
+		t.Errorf("Wrong signed out JSON response: %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestHomeJSONKeepsTheStatusOfErrors(t *testing.T) {
+	g := newDevGitHub(time.Now())
+	server, client, stop := startDevServer(t, g)
+	defer stop()
+	signIn(t, server, client, "webdev")
+	g.setDown(true)
+
+	statuses := map[string]int{}
+	for _, accept := range []string{"text/html", "application/json"} {
+		req, _ := http.NewRequest("GET", server.URL+"/", nil)
+		req.Header.Set("Accept", accept)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		statuses[accept] = resp.StatusCode
+	}
+	if statuses["application/json"] == http.StatusUnauthorized || statuses["application/json"] != statuses["text/html"] {
+		t.Errorf("Wrong JSON status when GitHub fails: %v", statuses)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const oauthStateCookie = "oauth_state"
+
+// login starts the OAuth flow. It ties the flow to the browser with a
+// random state, kept in a cookie and sent to GitHub, which callback checks
+// before exchanging the code, so nobody can sign a visitor in to their
+// own account (login CSRF). Redirecting from here, rather than rendering
+// the state in the landing page, keeps that page cacheable. A local
+// server with a personal access token signs in with it right away, but
+// only browsers on its own host.
+func login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	if localToken != "" {
+		if !fromLoopback(r) {
+			httpError(w, r, "This server signs in with the token of its owner, so only from their own host", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: localToken, Path: "/", HttpOnly: true})
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	state, err := newOAuthState()
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/callback",
+		MaxAge:   10 * 60,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, gitHubURL+gitHubAuthorizePath+"?"+url.Values{
+		"scope":     []string{r.FormValue("scope")},
+		"client_id": []string{gitHubClientID},
+		"state":     []string{state},
+	}.Encode(), http.StatusFound)
+}
+
+// loginURL returns the URL that starts the OAuth flow requesting the
+// given scope. No scope gives read-only access to public information.
+func loginURL(scope string) string {
+	if scope == "" {
+		return "/login"
+	}
+	return "/login?" + url.Values{"scope": []string{scope}}.Encode()
+}
+
+func newOAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Unable to generate OAuth state: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// checkOAuthState tells whether the state GitHub sent back to callback is
+// the one login gave this browser, and forgets it either way.
+func checkOAuthState(w http.ResponseWriter, r *http.Request) bool {
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/callback", MaxAge: -1, HttpOnly: true})
+	cookie, _ := r.Cookie(oauthStateCookie)
+	state := r.FormValue("state")
+	return cookie != nil && state != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLoginState(t *testing.T) {
+	w := httptest.NewRecorder()
+	login(w, httptest.NewRequest("GET", "/login?scope=public_repo", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Wrong status: %d", w.Code)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect: %v", err)
+	}
+	state := location.Query().Get("state")
+	if state == "" || location.Query().Get("scope") != "public_repo" {
+		t.Fatalf("Wrong redirect: %s", location)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || cookies[0].Value != state {
+		t.Fatalf("State is not kept in a cookie: %v", cookies)
+	}
+
+	tests := []struct {
+		cookie, state string
+		want          bool
+	}{
+		{state, state, true},
+		{state, "forged", false},
+		{"", state, false},
+		{state, "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/callback?code=c&state="+tt.state, nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: tt.cookie})
+		}
+		if got := checkOAuthState(httptest.NewRecorder(), r); got != tt.want {
+			t.Errorf("checkOAuthState(cookie %q, state %q) = %v, want %v", tt.cookie, tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestCallbackRejectsMissingState(t *testing.T) {
+	w := httptest.NewRecorder()
+	callback(w, httptest.NewRequest("GET", "/callback?code=c", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Wrong status: %d", w.Code)
+	}
+}
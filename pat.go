@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// fine-grained personal access tokens have no scopes, but permissions
+	// GitHub reports in X-Accepted-GitHub-Permissions when one is missing
+	fineGrainedTokenPrefix = "github_pat_"
+	fineGrainedTokenURL    = "https://github.com/settings/personal-access-tokens"
+	classicTokenURL        = "https://github.com/settings/tokens"
+	// tokenCheckTimeout bounds the preflight checks of a token
+	tokenCheckTimeout = 10 * time.Second
+)
+
+// localToken is the personal access token every visitor of a local server
+// is signed in with, instead of going through OAuth. It is set with
+// UseLocalToken.
+var localToken string
+
+// UseLocalToken signs in every visitor with a personal access token, fine
+// grained or classic, instead of the OAuth flow, to run the server locally
+// without an OAuth app. It checks first that GitHub accepts the token and
+// that it may read the stars of its user, and returns their login and
+// warnings about what else the token lacks. The token ends up in the
+// cookie of the browser, so it is only meant for a server of one's own,
+// and only given to browsers on the same host.
+func UseLocalToken(token string) (login string, warnings []string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+	defer cancel()
+	login, warnings, err = checkPersonalToken(ctx, token)
+	if err != nil {
+		return "", nil, err
+	}
+	localToken = token
+	return login, warnings, nil
+}
+
+// fromLoopback tells whether a request comes from the host of the server
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// gitHubAuthorization returns the Authorization header of a token. GitHub
+// documents the Bearer scheme for fine-grained tokens, and token for the
+// others, which predate it.
+func gitHubAuthorization(token string) string {
+	if strings.HasPrefix(token, fineGrainedTokenPrefix) {
+		return "Bearer " + token
+	}
+	return "token " + token
+}
+
+// checkPersonalToken checks that GitHub accepts a personal access token,
+// and that it may read the stars of its user, with errors that say how to
+// fix the token. Reading public stars needs no scope, but starring needs
+// the public_repo scope of classic tokens, which is only warned about.
+func checkPersonalToken(ctx context.Context, token string) (login string, warnings []string, err error) {
+	settings := classicTokenURL
+	if strings.HasPrefix(token, fineGrainedTokenPrefix) {
+		settings = fineGrainedTokenURL
+	}
+
+	var user gitHubUserResponse
+	resp, err := tokenGet(ctx, token, gitHubAPIURL+gitHubAuthenticatedUserPath, &user)
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", nil, fmt.Errorf("GitHub rejected the token: it is invalid, expired or revoked. Create a new one at %s", settings)
+	case resp.StatusCode != http.StatusOK || user.User == "":
+		return "", nil, fmt.Errorf("Unable to check the token, unexpected status from GitHub: %s", resp.Status)
+	}
+	// only classic tokens have scopes
+	if scopes, ok := resp.Header["X-Oauth-Scopes"]; ok && !hasScope(strings.Join(scopes, ","), "public_repo", "repo") {
+		warnings = append(warnings, fmt.Sprintf("The token cannot star repositories without the public_repo scope; add it at %s", settings))
+	}
+
+	var stars []gitHubStarredResponse
+	resp, err = tokenGet(ctx, token, gitHubAPIURL+"/user/starred?per_page=1", &stars)
+	if err != nil {
+		return "", nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound {
+		permissions := resp.Header.Get("X-Accepted-GitHub-Permissions")
+		if permissions == "" {
+			permissions = "starring=read"
+		}
+		return "", nil, fmt.Errorf("The token may not read the stars of %s: grant it the %s user permission at %s", user.User, permissions, settings)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("Unable to check the token, unexpected status from GitHub: %s", resp.Status)
+	}
+	return user.User, warnings, nil
+}
+
+// tokenGet gets a GitHub API URL with a token, decoding the body into
+// result if the request succeeds
+func tokenGet(ctx context.Context, token, url string, result interface{}) (*http.Response, error) {
+	req, err := newPublicGitHubRequest(ctx, url, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", gitHubAuthorization(token))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to reach GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// hasScope tells whether a comma separated list of scopes, as in the
+// X-OAuth-Scopes header, has any of the given ones
+func hasScope(scopes string, any ...string) bool {
+	for _, scope := range strings.Split(scopes, ",") {
+		for _, s := range any {
+			if strings.TrimSpace(scope) == s {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckPersonalToken(t *testing.T) {
+	// tokens are named after what GitHub makes of them
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch {
+		case strings.HasSuffix(auth, "revoked"):
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case strings.HasPrefix(auth, "token ghp_"):
+			w.Header().Set("X-OAuth-Scopes", strings.TrimPrefix(auth, "token ghp_"))
+		case !strings.HasPrefix(auth, "Bearer github_pat_"):
+			t.Errorf("Wrong Authorization header: %q", auth)
+		}
+		if r.URL.Path == "/user" {
+			fmt.Fprintln(w, `{"login": "octocat"}`)
+			return
+		}
+		if strings.HasSuffix(auth, "nostars") {
+			w.Header().Set("X-Accepted-GitHub-Permissions", "starring=read")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintln(w, `[]`)
+	}))
+	defer server.Close()
+	defer func(saved string) { gitHubAPIURL = saved }(gitHubAPIURL)
+	gitHubAPIURL = server.URL
+
+	tests := []struct {
+		token    string
+		warnings int
+		err      string
+	}{
+		{"github_pat_ok", 0, ""},
+		{"ghp_public_repo", 0, ""},
+		{"ghp_read:user", 1, ""},
+		{"github_pat_revoked", 0, "invalid, expired or revoked. Create a new one at " + fineGrainedTokenURL},
+		{"github_pat_nostars", 0, "grant it the starring=read user permission"},
+	}
+	for _, tt := range tests {
+		login, warnings, err := checkPersonalToken(context.Background(), tt.token)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: wrong error %v", tt.token, err)
+			}
+			continue
+		}
+		if err != nil || login != "octocat" || len(warnings) != tt.warnings {
+			t.Errorf("%s: %q %v %v", tt.token, login, warnings, err)
+		}
+	}
+}
+
+func TestLocalTokenLogin(t *testing.T) {
+	defer func(saved string) { localToken = saved }(localToken)
+	localToken = "github_pat_local"
+	r := httptest.NewRequest("GET", "/login", nil)
+	r.RemoteAddr = "127.0.0.1:54321"
+	w := httptest.NewRecorder()
+	login(w, r)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/" || len(cookies) != 1 || cookies[0].Value != localToken {
+		t.Errorf("Local token did not sign in: %d %v", w.Code, w.Header())
+	}
+
+	for _, addr := range []string{"192.0.2.1:1234", "[2001:db8::1]:1234", "localhost"} {
+		r := httptest.NewRequest("GET", "/login", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		login(w, r)
+		if w.Code != http.StatusForbidden || len(w.Result().Cookies()) != 0 {
+			t.Errorf("Local token given to %s: %d %v", addr, w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// This file holds what the server needs from where it runs: contexts,
+// outgoing requests and logging. See cmd/github-recs for the binary.
+
+var (
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+	debugLog   = os.Getenv("DEBUG") != ""
+)
+
+// detachRequest returns a copy of r whose context is not cancelled when
+// its response is sent, to work on its behalf in the background.
+func detachRequest(r *http.Request) *http.Request {
+	return r.WithContext(context.Background())
+}
+
+func logErrorf(format string, args ...interface{}) {
+	log.Printf("ERROR: "+format, args...)
+}
+
+func logWarningf(format string, args ...interface{}) {
+	log.Printf("WARNING: "+format, args...)
+}
+
+func logDebugf(format string, args ...interface{}) {
+	if debugLog {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+type (
+	// RepositoryScore is a recommended repository and its score
+	RepositoryScore = recommender.RepositoryScore
+
+	// Filter decides whether a repository may be recommended. Forks
+	// register filters at startup to hide repositories from every list.
+	Filter = recommender.Filter
+
+	// FilterFunc adapts a function to the Filter interface
+	FilterFunc = recommender.FilterFunc
+
+	// Enricher adds data to recommendations before they are returned.
+	// Enrich returns a value per repository full name; it is exposed to
+	// templates and JSON clients under the name the enricher was
+	// registered with.
+	Enricher interface {
+		Enrich(r *http.Request, recs []RepositoryScore) (map[string]interface{}, error)
+	}
+
+	registeredEnricher struct {
+		name string
+		Enricher
+	}
+)
+
+var (
+	filters   []Filter
+	enrichers []registeredEnricher
+)
+
+// RegisterFilter adds a filter applied to all recommendations. It must be
+// called before the server starts handling requests, e.g. from init.
+func RegisterFilter(f Filter) {
+	filters = append(filters, f)
+}
+
+// RegisterEnricher adds an enricher run on all recommendations served to
+// signed in users. It must be called before the server starts handling
+// requests, e.g. from init.
+func RegisterEnricher(name string, e Enricher) {
+	enrichers = append(enrichers, registeredEnricher{name, e})
+}
+
+// enrich runs the registered enrichers. Failures are logged and leave
+// that enricher's data out.
+func enrich(r *http.Request, recs []RepositoryScore) map[string]map[string]interface{} {
+	if len(enrichers) == 0 {
+		return nil
+	}
+	extras := map[string]map[string]interface{}{}
+	for _, e := range enrichers {
+		data, err := e.Enrich(r, recs)
+		if err != nil {
+			logWarningf("Enricher %s failed: %v", e.name, err)
+			continue
+		}
+		extras[e.name] = data
+	}
+	return extras
+}
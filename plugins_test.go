@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type staticEnricher map[string]interface{}
+
+func (e staticEnricher) Enrich(r *http.Request, recs []RepositoryScore) (map[string]interface{}, error) {
+	if e == nil {
+		return nil, errors.New("failed")
+	}
+	return e, nil
+}
+
+func TestEnrich(t *testing.T) {
+	defer func(saved []registeredEnricher) { enrichers = saved }(enrichers)
+	enrichers = nil
+	RegisterEnricher("license", staticEnricher{"BVLC/caffe": "BSD-2-Clause"})
+	RegisterEnricher("broken", staticEnricher(nil))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	got := enrich(r, []RepositoryScore{{Repository: "BVLC/caffe"}})
+	want := map[string]map[string]interface{}{"license": {"BVLC/caffe": "BSD-2-Clause"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("enrich = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbochi/github-recs/lru"
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	// gitHubPublicStarredPath lists the public stars of any user, under
+	// gitHubAPIURL, without their token
+	gitHubPublicStarredPath = "/users/%s/starred?per_page=100"
+	userPathPrefix          = "/u/"
+	// rateLimitRetryAfter is the Retry-After, in seconds, of responses
+	// served while GitHub rate limits the public API
+	rateLimitRetryAfter = 300
+	// publicStarsTTL is how long the public stars of a user are reused
+	// before they are fetched again, so sharing a page or listing a user in
+	// several batches costs GitHub a single round of requests
+	publicStarsTTL = 5 * time.Minute
+	// publicStarsCacheBytes bounds the public stars kept; the least
+	// recently used go first
+	publicStarsCacheBytes = 16 << 20
+	// cachedStarSize approximates the overhead of a star in the cache,
+	// besides the name of its repository
+	cachedStarSize = 64
+)
+
+type cachedStars struct {
+	repos   []gitHubRepository
+	expires time.Time
+}
+
+var (
+	errUnknownGitHubUser = errors.New("Unknown GitHub user")
+	errGitHubRateLimited = errors.New("GitHub rate limit exceeded")
+
+	// gitHubAppToken authenticates the requests to the public API, set
+	// with GITHUB_TOKEN, which raises their rate limit from 60 to 5000 an
+	// hour. It needs no scope.
+	gitHubAppToken string
+
+	gitHubLogin = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+	// publicStars are the public stars of the users seen recently, by the
+	// URL of their first page, as logins are case insensitive
+	publicStars = lru.New(publicStarsCacheBytes)
+)
+
+// publicStarredRepositories returns the public stars of a GitHub user,
+// following their pages up to starPageLimit, like starredRepositories,
+// but without a token of theirs. They are cached for publicStarsTTL, and
+// must not be modified.
+func publicStarredRepositories(ctx context.Context, login string) ([]gitHubRepository, error) {
+	if !gitHubLogin.MatchString(login) {
+		return nil, fmt.Errorf("Invalid GitHub user %q", login)
+	}
+	first := gitHubAPIURL + fmt.Sprintf(gitHubPublicStarredPath, strings.ToLower(login))
+	now := time.Now()
+	if value, ok := publicStars.Get(first); ok && now.Before(value.(cachedStars).expires) {
+		return value.(cachedStars).repos, nil
+	}
+	repos, err := starredPages(first, func(url string) (*http.Response, error) {
+		req, err := newPublicGitHubRequest(ctx, url, gitHubStarMediaType)
+		if err != nil {
+			return nil, err
+		}
+		return hedgedDo(ctx, httpClient, req, hedgeDelay)
+	})
+	if err != nil {
+		return repos, err
+	}
+	size := int64(cachedStarSize)
+	for _, repo := range repos {
+		size += int64(len(repo.Repository)) + cachedStarSize
+	}
+	publicStars.Add(first, cachedStars{repos, now.Add(publicStarsTTL)}, size)
+	return repos, nil
+}
+
+// newPublicGitHubRequest creates a GET request to the public GitHub API,
+// on behalf of no user, with the app token if there is one
+func newPublicGitHubRequest(ctx context.Context, url string, accept string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if gitHubAppToken != "" {
+		req.Header.Set("Authorization", gitHubAuthorization(gitHubAppToken))
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", gitHubUserAgent)
+	return req, nil
+}
+
+// userPage renders the recommendations for the public stars of any GitHub
+// user, as in /u/octocat, without their signing in. It is not
+// personalized, so anyone can share it and shared caches can keep it.
+func userPage(w http.ResponseWriter, r *http.Request) {
+	model, version := modelForRequest(r)
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+	login := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, userPathPrefix), "/")
+	if !gitHubLogin.MatchString(login) {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Sorry, %q is not a GitHub user name.", login))
+		return
+	}
+
+	repos, err := publicStarredRepositories(r.Context(), login)
+	switch err {
+	case nil:
+	case errUnknownGitHubUser:
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Sorry, I don't know the GitHub user %q.", login))
+		return
+	case errGitHubRateLimited:
+		w.Header().Set("Retry-After", strconv.Itoa(rateLimitRetryAfter))
+		renderError(w, r, http.StatusServiceUnavailable, "Sorry, GitHub is not answering me right now. Please come back in a few minutes.")
+		return
+	default:
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	stars := repositoryNames(repos)
+
+	recs, err := model.Recommend(r.Context(), stars, numRecommendations, recommender.Filters(filters...))
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	setPublicCacheHeaders(w, "user", "user/"+strings.ToLower(login))
+	render(w, r, "recs", recommendationsTemplateVars{
+		User:         login,
+		Stars:        stars,
+		StarPreview:  previewStars(stars),
+		Recs:         recs,
+		Demo:         true,
+		Shared:       true,
+		ModelVersion: version,
+	})
+}
+
+// userPath returns the path of the recommendations page of a GitHub user
+func userPath(login string) string {
+	return userPathPrefix + login
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUserPage(t *testing.T) {
+	server, client, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/u/ml-researcher", http.StatusOK, "public stars of <b>ml-researcher</b>"},
+		{"/u/private-eye", http.StatusOK, "<b>private-eye</b> has not starred any public repos"},
+		{"/u/nobody-at-all", http.StatusNotFound, "I don&#39;t know the GitHub user"},
+		{"/u/not..valid", http.StatusNotFound, "is not a GitHub user name"},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || !strings.Contains(string(body), tt.want) {
+			t.Errorf("%s: %d, missing %q", tt.path, resp.StatusCode, tt.want)
+		}
+		if tt.status == http.StatusOK && resp.Header.Get("Cache-Control") != publicCacheControl {
+			t.Errorf("%s is not cacheable: %q", tt.path, resp.Header.Get("Cache-Control"))
+		}
+	}
+}
+
+func TestUserPageRateLimited(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token app-token" {
+			t.Errorf("The app token was not sent: %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer github.Close()
+	defer func(url, token string) { gitHubAPIURL, gitHubAppToken = url, token }(gitHubAPIURL, gitHubAppToken)
+	gitHubAPIURL, gitHubAppToken = github.URL, "app-token"
+
+	w := httptest.NewRecorder()
+	userPage(w, httptest.NewRequest("GET", "/u/octocat", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Wrong response when rate limited: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestPublicStarsAreCached(t *testing.T) {
+	var requests int32
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/users/nobody/starred" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `[{"repo": {"full_name": "golang/go"}}]`)
+	}))
+	defer github.Close()
+	defer func(url string) { gitHubAPIURL = url }(gitHubAPIURL)
+	gitHubAPIURL = github.URL
+
+	for _, login := range []string{"octocat", "OctoCat", "nobody", "nobody"} {
+		publicStarredRepositories(context.Background(), login)
+	}
+	if requests != 3 {
+		t.Errorf("Expected the stars of octocat to be fetched once, and unknown users every time: %d requests", requests)
+	}
+	repos, err := publicStarredRepositories(context.Background(), "octocat")
+	if err != nil || fmt.Sprint(repositoryNames(repos)) != "[golang/go]" {
+		t.Errorf("Wrong cached stars: %v %v", repos, err)
+	}
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbochi/github-recs/lru"
+)
+
+const (
+	gitHubReadmePath = "/repos/%s/readme"
+	maxReadmeSize    = 512 * 1024
+	maxSummaryLength = 300
+
+	// summaryMaxAge is how long a summary is served before it is
+	// refreshed. Stale summaries are still served while they are.
+	summaryMaxAge = 24 * time.Hour
+	// summaryCacheBytes bounds the summaries kept, which is enough for
+	// tens of thousands of them
+	summaryCacheBytes = 16 << 20
+	// cachedSummarySize approximates the overhead of a summary in the
+	// cache, besides its text
+	cachedSummarySize = 64
+)
+
+type (
+	// Summarizer turns the contents of a README into a short summary that
+	// is rendered next to a recommendation
+	Summarizer interface {
+		Summarize(ctx context.Context, readme string) (string, error)
+	}
+
+	// FirstParagraphSummarizer summarizes a README by its first paragraph
+	// of prose, skipping headings, badges, HTML and code blocks
+	FirstParagraphSummarizer struct{}
+
+	// summaryCache keeps summaries with stale-while-revalidate semantics:
+	// once a summary is older than maxAge it is still returned, and
+	// refreshed in the background by a single caller. The least recently
+	// used summaries are evicted beyond the size of the cache.
+	summaryCache struct {
+		sync.Mutex
+		maxAge     time.Duration
+		summaries  *lru.Cache
+		refreshing map[string]bool
+	}
+
+	cachedSummary struct {
+		summary string
+		fetched time.Time
+	}
+)
+
+var (
+	summarizer Summarizer = FirstParagraphSummarizer{}
+	summaries             = newSummaryCache(summaryMaxAge, summaryCacheBytes)
+
+	markdownImage    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile("[*_`]+")
+	htmlTag          = regexp.MustCompile(`<[^>]+>`)
+)
+
+func newSummaryCache(maxAge time.Duration, maxBytes int64) *summaryCache {
+	return &summaryCache{
+		maxAge:     maxAge,
+		summaries:  lru.New(maxBytes),
+		refreshing: map[string]bool{},
+	}
+}
+
+// get returns the cached summary of a repository. refresh is true if it is
+// stale and the caller is the one that should refresh it, by calling set
+// or refreshFailed.
+func (c *summaryCache) get(repo string, now time.Time) (summary string, ok bool, refresh bool) {
+	c.Lock()
+	defer c.Unlock()
+	value, ok := c.summaries.Get(repo)
+	s, _ := value.(cachedSummary)
+	if ok && now.Sub(s.fetched) > c.maxAge && !c.refreshing[repo] {
+		c.refreshing[repo] = true
+		refresh = true
+	}
+	return s.summary, ok, refresh
+}
+
+func (c *summaryCache) set(repo, summary string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.summaries.Add(repo, cachedSummary{summary, now}, int64(len(summary))+cachedSummarySize)
+	delete(c.refreshing, repo)
+}
+
+// refreshFailed lets a later get refresh the summary again
+func (c *summaryCache) refreshFailed(repo string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.refreshing, repo)
+}
+
+// Summarize returns the first paragraph of the README
+func (FirstParagraphSummarizer) Summarize(ctx context.Context, readme string) (string, error) {
+	var paragraph []string
+	inCode := false
+	lines := strings.Split(strings.Replace(readme, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if line == "" {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		// setext headings are underlined by a line of "=" or "-"
+		if i+1 < len(lines) && isSetextUnderline(lines[i+1]) {
+			paragraph = nil
+			continue
+		}
+		if isSetextUnderline(line) || !isProse(line) {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	return truncate(cleanMarkdown(strings.Join(paragraph, " ")), maxSummaryLength), nil
+}
+
+func isSetextUnderline(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && (strings.Trim(line, "=") == "" || strings.Trim(line, "-") == "")
+}
+
+func isProse(line string) bool {
+	for _, prefix := range []string{"#", "!", "[!", "<", "|", ">", "* ", "- ", "+ "} {
+		if strings.HasPrefix(line, prefix) {
+			return false
+		}
+	}
+	return strings.TrimSpace(markdownImage.ReplaceAllString(line, "")) != ""
+}
+
+func cleanMarkdown(text string) string {
+	text = markdownImage.ReplaceAllString(text, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	cut := strings.LastIndex(text[:n], " ")
+	if cut <= 0 {
+		cut = n
+	}
+	return strings.TrimRight(text[:cut], ".,;:") + "…"
+}
+
+// summarizeRepositories fetches the README summaries of the recommended
+// repositories concurrently. Failures are logged and leave the summary out.
+func summarizeRepositories(r *http.Request, recs []RepositoryScore) map[string]string {
+	result := make(map[string]string, len(recs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, rec := range recs {
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			summary, err := repositorySummary(r, repo)
+			if err != nil {
+				logWarningf("Unable to summarize %s: %v", repo, err)
+				return
+			}
+			mu.Lock()
+			result[repo] = summary
+			mu.Unlock()
+		}(rec.Repository)
+	}
+	wg.Wait()
+	return result
+}
+
+// repositorySummary returns the cached summary of a repository, fetching
+// it only if it was never cached. Stale summaries are returned right away
+// and refreshed in the background, so pages do not wait on GitHub.
+func repositorySummary(r *http.Request, repo string) (string, error) {
+	summary, ok, refresh := summaries.get(repo, time.Now())
+	if refresh {
+		go func(r *http.Request) {
+			if _, err := fetchSummary(r, repo); err != nil {
+				summaries.refreshFailed(repo)
+				logWarningf("Unable to refresh the summary of %s: %v", repo, err)
+			}
+		}(detachRequest(r))
+	}
+	if ok {
+		return summary, nil
+	}
+	return fetchSummary(r, repo)
+}
+
+// fetchSummary summarizes the README of a repository and caches it
+func fetchSummary(r *http.Request, repo string) (string, error) {
+	readme, err := fetchReadme(r, repo)
+	if err != nil {
+		return "", err
+	}
+	summary := ""
+	if readme != "" {
+		summary, err = summarizer.Summarize(r.Context(), readme)
+		if err != nil {
+			return "", err
+		}
+	}
+	summaries.set(repo, summary, time.Now())
+	return summary, nil
+}
+
+// fetchReadme returns the raw README of a repository, or an empty string
+// if it has none.
+func fetchReadme(r *http.Request, repo string) (string, error) {
+	parts := strings.SplitN(repo, "/", 3)
+	resp, err := gitHubGet(r, gitHubAPIURL+fmt.Sprintf(gitHubReadmePath, parts[0]+"/"+parts[1]), "application/vnd.github.v3.raw")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected status from GitHub: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxReadmeSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFirstParagraphSummarizer(t *testing.T) {
+	tests := []struct {
+		readme string
+		want   string
+	}{
+		{"# Caffe\n\n[![Build Status](https://travis-ci.org/BVLC/caffe.svg)](https://travis-ci.org/BVLC/caffe)\n\nCaffe is a *deep learning* framework made with\n[expression](http://example.com), speed, and modularity in mind.\n\nCheck out the [project site](http://caffe.berkeleyvision.org).",
+			"Caffe is a deep learning framework made with expression, speed, and modularity in mind."},
+		{"Project\n=======\n\n```\nnpm install\n```\n\n<p align=\"center\"><img src=\"logo.png\"></p>\n\nA `tiny` library.\r\n",
+			"A tiny library."},
+		{"# Only a heading\n", ""},
+	}
+	for _, tt := range tests {
+		got, err := FirstParagraphSummarizer{}.Summarize(context.Background(), tt.readme)
+		if err != nil {
+			t.Errorf("Failed to summarize: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Summarize(%q) = %q, want %q", tt.readme, got, tt.want)
+		}
+	}
+}
+
+func TestSummaryIsTruncated(t *testing.T) {
+	readme := strings.Repeat("word ", 200)
+	got, _ := FirstParagraphSummarizer{}.Summarize(context.Background(), readme)
+	if len(got) > maxSummaryLength+len("…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Summary was not truncated: %q", got)
+	}
+}
+
+func TestSummaryCacheStaleWhileRevalidate(t *testing.T) {
+	c := newSummaryCache(time.Hour, summaryCacheBytes)
+	now := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	if _, ok, _ := c.get("BVLC/caffe", now); ok {
+		t.Fatalf("Empty cache returned a summary")
+	}
+	c.set("BVLC/caffe", "A framework.", now)
+
+	if s, ok, refresh := c.get("BVLC/caffe", now.Add(time.Minute)); !ok || refresh || s != "A framework." {
+		t.Errorf("Fresh summary: %q %v %v", s, ok, refresh)
+	}
+	later := now.Add(2 * time.Hour)
+	if s, ok, refresh := c.get("BVLC/caffe", later); !ok || !refresh || s != "A framework." {
+		t.Errorf("Stale summary was not served for refresh: %q %v %v", s, ok, refresh)
+	}
+	if _, _, refresh := c.get("BVLC/caffe", later); refresh {
+		t.Errorf("Stale summary was refreshed twice")
+	}
+	c.refreshFailed("BVLC/caffe")
+	if _, _, refresh := c.get("BVLC/caffe", later); !refresh {
+		t.Errorf("Failed refresh was not retried")
+	}
+	c.set("BVLC/caffe", "A deep learning framework.", later)
+	if s, _, refresh := c.get("BVLC/caffe", later); refresh || s != "A deep learning framework." {
+		t.Errorf("Refreshed summary: %q %v", s, refresh)
+	}
+}
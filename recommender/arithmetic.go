@@ -0,0 +1,94 @@
+package recommender
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Term is a repository of an Arithmetic query and the weight its factors
+// are added with, negative to subtract them
+type Term struct {
+	Repository string  `json:"repository"`
+	Weight     float64 `json:"weight"`
+}
+
+// ParseArithmetic parses an expression that adds and subtracts
+// repositories, as in "kubernetes/kubernetes - golang/go + rust-lang/rust".
+// The signs are separate words, or prefix the repository they apply to.
+func ParseArithmetic(expression string) ([]Term, error) {
+	var terms []Term
+	sign, signed := 1.0, true
+	for _, word := range strings.Fields(expression) {
+		for len(word) > 0 && (word[0] == '+' || word[0] == '-') {
+			if word[0] == '-' {
+				sign = -sign
+			}
+			signed = true
+			word = word[1:]
+		}
+		if word == "" {
+			continue
+		}
+		if !signed {
+			return nil, fmt.Errorf("Missing + or - before %s", word)
+		}
+		terms = append(terms, Term{Repository: word, Weight: sign})
+		sign, signed = 1, false
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("Missing repositories to add or subtract")
+	}
+	if signed {
+		return nil, fmt.Errorf("Missing a repository after the last sign")
+	}
+	return terms, nil
+}
+
+// Arithmetic returns the n repositories most similar to a weighted sum of
+// the factors of the terms, as in word2vec analogies: "kubernetes -
+// golang + rust" finds what kubernetes is to Go in Rust. The factors are
+// normalized before they are added, so popular repositories do not
+// outweigh the others, and the repositories of the terms are left out.
+func (m *Model) Arithmetic(terms []Term, n int, opts ...RecommendOption) ([]RepositoryScore, error) {
+	if err := checkN(n); err != nil {
+		return nil, err
+	}
+	o, err := m.newRecommendOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("Missing repositories to add or subtract")
+	}
+	excluded := m.seenDocs(o.excludeRepositories)
+	target := make([]float64, m.nFactors)
+	for _, term := range terms {
+		id, ok := m.RepositoryID(term.Repository)
+		if !ok {
+			return nil, fmt.Errorf("Unknown repository: %s", term.Repository)
+		}
+		excluded[id] = true
+		if m.norms[id] == 0 {
+			continue
+		}
+		for k, v := range m.vector(id) {
+			target[k] += term.Weight * v / m.norms[id]
+		}
+	}
+	excluded = m.withAliases(excluded)
+	norm := math.Sqrt(dot(target, target))
+
+	top := newTopScores(n + m.redundantAliases)
+	for id := range m.repositories {
+		if excluded[id] || !m.allowed(o, id) {
+			continue
+		}
+		var score float64
+		if norm > 0 && m.norms[id] > 0 {
+			score = dot(target, m.vector(id)) / (norm * m.norms[id])
+		}
+		top.offer(documentScore{id, score})
+	}
+	return m.repositoryScores(top.sorted(), n), nil
+}
@@ -0,0 +1,75 @@
+package recommender
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArithmetic(t *testing.T) {
+	for expression, expected := range map[string][]Term{
+		"kubernetes/kubernetes - golang/go + rust-lang/rust": {{"kubernetes/kubernetes", 1}, {"golang/go", -1}, {"rust-lang/rust", 1}},
+		"-golang/go +rust-lang/rust":                         {{"golang/go", -1}, {"rust-lang/rust", 1}},
+		"golang/go - - rust-lang/rust":                       {{"golang/go", 1}, {"rust-lang/rust", 1}},
+	} {
+		terms, err := ParseArithmetic(expression)
+		if err != nil || !reflect.DeepEqual(terms, expected) {
+			t.Errorf("Wrong terms of %q: %v %v", expression, terms, err)
+		}
+	}
+	for _, expression := range []string{"", " + ", "golang/go rust-lang/rust", "golang/go -"} {
+		if terms, err := ParseArithmetic(expression); err == nil {
+			t.Errorf("Expected an error for %q, got %v", expression, terms)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	// a single repository is an arithmetic query for the similar ones
+	similar, err := model.Similar("golang/go", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := model.Arithmetic([]Term{{"golang/go", 2}}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum) != len(similar) {
+		t.Fatalf("Expected %v, got %v", similar, sum)
+	}
+	for i := range sum {
+		if sum[i].Repository != similar[i].Repository {
+			t.Errorf("Expected %v, got %v", similar, sum)
+			break
+		}
+	}
+
+	terms, err := ParseArithmetic("kubernetes/kubernetes - golang/go + rust-lang/rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := model.Arithmetic(terms, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of results: %v", recs)
+	}
+	for _, rec := range recs {
+		for _, term := range terms {
+			if rec.Repository == term.Repository {
+				t.Errorf("Expected the terms to be left out: %v", recs)
+			}
+		}
+	}
+
+	if _, err := model.Arithmetic(terms, -1); err == nil {
+		t.Errorf("Expected an error for a negative n")
+	}
+	if _, err := model.Arithmetic([]Term{{"golang/go", 1}, {"not/there", -1}}, 10); err == nil {
+		t.Errorf("Expected an error for an unknown repository")
+	}
+}
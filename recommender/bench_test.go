@@ -0,0 +1,160 @@
+package recommender
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var (
+	scalingFull = flag.Bool("scaling.full", false, "run BenchmarkScaling on every vocabulary size, up to 1M repositories, which takes long")
+	scalingCSV  = flag.String("scaling.csv", "", "append the results of BenchmarkScaling to this CSV file")
+)
+
+var (
+	scalingVocabularies = []int{10000, 100000, 1000000}
+	scalingDimensions   = []int{32, 64, 128, 256}
+)
+
+// BenchmarkScaling measures loading, Recommend and Similar on generated
+// models of growing vocabulary and dimensions, to track how they scale:
+//
+//	go test -run XXX -bench Scaling -scaling.full -scaling.csv scaling.csv
+//
+// Without -scaling.full, only the 10k repositories models run. Each CSV
+// row is the time of the run, a benchmark, the size of its model and its
+// ns/op.
+func BenchmarkScaling(b *testing.B) {
+	vocabularies := scalingVocabularies
+	if !*scalingFull {
+		vocabularies = vocabularies[:1]
+	}
+	var names []string
+	results := map[string][]string{}
+	record := func(b *testing.B, operation string, vocabulary, dimensions int) {
+		ns := b.Elapsed().Nanoseconds() / int64(b.N)
+		// the last run of a benchmark is the one reported
+		if results[b.Name()] == nil {
+			names = append(names, b.Name())
+		}
+		results[b.Name()] = []string{operation, strconv.Itoa(vocabulary), strconv.Itoa(dimensions), strconv.FormatInt(ns, 10)}
+	}
+
+	for _, vocabulary := range vocabularies {
+		for _, dimensions := range scalingDimensions {
+			name := fmt.Sprintf("vocab=%d/dims=%d", vocabulary, dimensions)
+			m := randomModel(vocabulary, dimensions, 1)
+			rng := rand.New(rand.NewSource(1))
+			var items []string
+			for i := 0; i < 20; i++ {
+				items = append(items, m.repositories[rng.Intn(vocabulary)])
+			}
+
+			b.Run("Load/"+name, func(b *testing.B) {
+				dir := writeBinaryModel(b, m)
+				defer os.RemoveAll(dir)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := ReadModel(dir + "/"); err != nil {
+						b.Fatalf("Unable to read model: %v", err)
+					}
+				}
+				record(b, "load", vocabulary, dimensions)
+			})
+			b.Run("Recommend/"+name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := m.Recommend(context.Background(), items, 10); err != nil {
+						b.Fatalf("Failed to recommend: %v", err)
+					}
+				}
+				record(b, "recommend", vocabulary, dimensions)
+			})
+			b.Run("Similar/"+name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := m.Similar(items[0], 10); err != nil {
+						b.Fatalf("Failed to find similar repositories: %v", err)
+					}
+				}
+				record(b, "similar", vocabulary, dimensions)
+			})
+		}
+	}
+
+	if *scalingCSV != "" {
+		var rows [][]string
+		for _, name := range names {
+			rows = append(rows, results[name])
+		}
+		if err := appendScalingCSV(*scalingCSV, time.Now(), rows); err != nil {
+			b.Fatalf("Unable to write %s: %v", *scalingCSV, err)
+		}
+	}
+}
+
+// randomModel generates a model with normally distributed factors
+func randomModel(vocabulary, dimensions int, seed int64) *Model {
+	rng := rand.New(rand.NewSource(seed))
+	data := make([]float64, vocabulary*dimensions)
+	for i := range data {
+		data[i] = rng.NormFloat64() * 0.1
+	}
+	repositories := make([]string, vocabulary)
+	for i := range repositories {
+		repositories[i] = fmt.Sprintf("owner%d/repository%d", i/10, i)
+	}
+	return newModel(data, dimensions, repositories)
+}
+
+// writeBinaryModel writes the binary model file of m in a new directory
+func writeBinaryModel(b *testing.B, m *Model) string {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		b.Fatalf("Unable to create model directory: %v", err)
+	}
+	f, err := os.Create(filepath.Join(dir, binaryModelFile))
+	if err != nil {
+		b.Fatalf("Unable to create model: %v", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := m.writeBinary(w); err != nil {
+		b.Fatalf("Unable to write model: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatalf("Unable to write model: %v", err)
+	}
+	return dir
+}
+
+// appendScalingCSV appends the rows of a run to a CSV file, with a header
+// if it is new
+func appendScalingCSV(filename string, now time.Time, rows [][]string) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write([]string{"time", "operation", "vocabulary", "dimensions", "ns_per_op"})
+	}
+	for _, row := range rows {
+		w.Write(append([]string{now.UTC().Format(time.RFC3339)}, row...))
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,190 @@
+package recommender
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// The binary model file holds the item factors and names of a model, so it
+// loads with a few large reads instead of parsing items.csv line by line.
+// Integers and floats are little-endian. It is laid out as:
+//
+//	magic          8 bytes, "GHRECS\x00\x00"
+//	version        uint32, binaryModelVersion
+//	repositories   uint32
+//	factors        uint32
+//	item factors   repositories x factors float32, row-major
+//	string table   for each repository, a uint16 length and the name
+//
+// Labels such as languages.csv stay in their own files.
+const (
+	binaryModelFile    = "model.bin"
+	binaryModelMagic   = "GHRECS\x00\x00"
+	binaryModelVersion = 1
+	// maxBinaryModelFactors bounds the factors of a binary model, far
+	// beyond those of any model trained, so a corrupt header cannot make
+	// it allocate gigabytes
+	maxBinaryModelFactors = 1 << 14
+)
+
+type binaryModelHeader struct {
+	Magic        [8]byte
+	Version      uint32
+	Repositories uint32
+	Factors      uint32
+}
+
+// ConvertModel writes the binary model file of the model in path, from
+// its item_factors.npy and items.csv, next to them. ReadModel prefers it
+// from then on, so it must be converted again whenever they change.
+func ConvertModel(path string) error {
+	m, err := readModel(path, 0, formatNpy)
+	if err != nil {
+		return err
+	}
+
+	// written aside and renamed, so readers never see a partial file
+	f, err := ioutil.TempFile(filepath.Dir(path+binaryModelFile), binaryModelFile)
+	if err != nil {
+		return fmt.Errorf("Unable to create %s: %v", binaryModelFile, err)
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	err = m.writeBinary(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write %s: %v", binaryModelFile, err)
+	}
+	return os.Rename(f.Name(), path+binaryModelFile)
+}
+
+func (m *Model) writeBinary(w io.Writer) error {
+	header := binaryModelHeader{
+		Version:      binaryModelVersion,
+		Repositories: uint32(len(m.repositories)),
+		Factors:      uint32(m.nFactors),
+	}
+	copy(header.Magic[:], binaryModelMagic)
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4*m.nFactors)
+	for id := range m.repositories {
+		for k, x := range m.vector(id) {
+			binary.LittleEndian.PutUint32(buf[4*k:], math.Float32bits(float32(x)))
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	for _, repo := range m.repositories {
+		if len(repo) > math.MaxUint16 {
+			return fmt.Errorf("Repository name is too long: %s", repo)
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint16(len(repo))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBinaryModel reads the item factors, row-major, and the names of the
+// repositories of a binary model file, checking the size of the model
+// against the budget before reading them.
+func readBinaryModel(filename string, budget int64) (data []float64, repositories []string, nFactors int, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Unable to open %s: %v", binaryModelFile, err)
+	}
+	defer f.Close()
+	data, repositories, nFactors, err = decodeBinaryModel(bufio.NewReader(f), budget)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Unable to read %s: %v", binaryModelFile, err)
+	}
+	return data, repositories, nFactors, nil
+}
+
+func decodeBinaryModel(r io.Reader, budget int64) (data []float64, repositories []string, nFactors int, err error) {
+	n, nFactors, err := decodeBinaryHeader(r)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if err := checkMemoryBudget(n, nFactors, false, budget); err != nil {
+		return nil, nil, 0, err
+	}
+
+	// within a budget the factors are allocated at once. Without one they
+	// are read a row at a time, so a corrupt header fails on a short read
+	// rather than on a huge allocation.
+	if budget > 0 {
+		data = make([]float64, 0, n*nFactors)
+	}
+	buf := make([]byte, 4*nFactors)
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, nil, 0, err
+		}
+		for k := 0; k < nFactors; k++ {
+			data = append(data, float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*k:]))))
+		}
+	}
+
+	repositories, err = decodeBinaryNames(r, n)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return data, repositories, nFactors, nil
+}
+
+// decodeBinaryHeader returns the number of repositories and factors of a
+// binary model file
+func decodeBinaryHeader(r io.Reader) (n, nFactors int, err error) {
+	var header binaryModelHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return 0, 0, err
+	}
+	if string(header.Magic[:]) != binaryModelMagic {
+		return 0, 0, fmt.Errorf("not a binary model file")
+	}
+	if header.Version != binaryModelVersion {
+		return 0, 0, fmt.Errorf("unsupported version %d, expected %d", header.Version, binaryModelVersion)
+	}
+	n, nFactors = int(header.Repositories), int(header.Factors)
+	if (nFactors == 0 && n > 0) || nFactors > maxBinaryModelFactors {
+		return 0, 0, fmt.Errorf("invalid number of factors %d", nFactors)
+	}
+	return n, nFactors, nil
+}
+
+// decodeBinaryNames reads the string table of a binary model file
+func decodeBinaryNames(r io.Reader, n int) ([]string, error) {
+	var repositories []string
+	var length uint16
+	for i := 0; i < n; i++ {
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, err
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, string(name))
+	}
+	return repositories, nil
+}
@@ -0,0 +1,121 @@
+package recommender
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertModel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatalf("Unable to create model directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"item_factors.npy", "items.csv"} {
+		data, err := ioutil.ReadFile("../data/" + name)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to copy %s: %v", name, err)
+		}
+	}
+	if err := ConvertModel(dir + "/"); err != nil {
+		t.Fatalf("Unable to convert model: %v", err)
+	}
+	// the binary file is all that is needed, and preferred
+	os.Remove(filepath.Join(dir, "item_factors.npy"))
+	os.Remove(filepath.Join(dir, "items.csv"))
+	converted, err := ReadModel(dir + "/")
+	if err != nil {
+		t.Fatalf("Unable to read converted model: %v", err)
+	}
+
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	if len(converted.repositories) != len(model.repositories) || converted.nFactors != model.nFactors {
+		t.Fatalf("Wrong shape: %d x %d", len(converted.repositories), converted.nFactors)
+	}
+	for id, vector := range model.vectors {
+		if converted.repositories[id] != model.repositories[id] {
+			t.Fatalf("Wrong name of %d: %s", id, converted.repositories[id])
+		}
+		for k, x := range vector {
+			if math.Abs(converted.vectors[id][k]-x) > 1e-6*math.Max(1, math.Abs(x)) {
+				t.Fatalf("Wrong factor %d of %s: %v, want %v", k, model.repositories[id], converted.vectors[id][k], x)
+			}
+		}
+	}
+
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	want, _ := model.Recommend(context.Background(), items, 10)
+	got, err := converted.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %v", err)
+	}
+	for i := range want {
+		if got[i].Repository != want[i].Repository {
+			t.Errorf("Recommendation %d is %s, want %s", i, got[i].Repository, want[i].Repository)
+		}
+	}
+}
+
+func TestDecodeBinaryModelErrors(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := model.writeBinary(&buf); err != nil {
+		t.Fatalf("Unable to write model: %v", err)
+	}
+	valid := buf.Bytes()
+	if _, _, _, err := decodeBinaryModel(bytes.NewReader(valid), 0); err != nil {
+		t.Fatalf("Unable to decode model: %v", err)
+	}
+
+	badMagic := append([]byte("NOTRECS!"), valid[8:]...)
+	badVersion := append([]byte(nil), valid...)
+	badVersion[8] = 99
+	for name, b := range map[string][]byte{
+		"empty":       nil,
+		"bad magic":   badMagic,
+		"bad version": badVersion,
+		"truncated":   valid[:len(valid)/2],
+	} {
+		if _, _, _, err := decodeBinaryModel(bytes.NewReader(b), 0); err == nil {
+			t.Errorf("Decoded a %s model", name)
+		}
+	}
+	if _, _, _, err := decodeBinaryModel(bytes.NewReader(valid), 1024); err == nil {
+		t.Errorf("Decoded a model over budget")
+	}
+}
+
+func FuzzDecodeBinaryModel(f *testing.F) {
+	model := &Model{
+		nFactors:     2,
+		vectors:      [][]float64{{1, 2}, {-0.5, 0}},
+		repositories: []string{"BVLC/caffe", "tensorflow/tensorflow"},
+	}
+	var buf bytes.Buffer
+	if err := model.writeBinary(&buf); err != nil {
+		f.Fatalf("Unable to write model: %v", err)
+	}
+	f.Add(buf.Bytes())
+	f.Add(buf.Bytes()[:20])
+	f.Add([]byte(binaryModelMagic + "\x01\x00\x00\x00\xff\xff\xff\xff\x00\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		data, repositories, nFactors, err := decodeBinaryModel(bytes.NewReader(b), 1<<20)
+		if err == nil && len(data) != len(repositories)*nFactors {
+			t.Errorf("Decoded %d factors for %d repositories of %d factors", len(data), len(repositories), nFactors)
+		}
+	})
+}
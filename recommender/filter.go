@@ -0,0 +1,24 @@
+package recommender
+
+type (
+	// Filter decides whether a repository may be recommended
+	Filter interface {
+		Allow(repo string) bool
+	}
+
+	// FilterFunc adapts a function to the Filter interface
+	FilterFunc func(repo string) bool
+)
+
+// Allow calls f(repo)
+func (f FilterFunc) Allow(repo string) bool {
+	return f(repo)
+}
+
+// Filters leaves out the repositories any of the filters do not allow
+func Filters(fs ...Filter) RecommendOption {
+	return func(o *recommendOptions) error {
+		o.filters = append(o.filters, fs...)
+		return nil
+	}
+}
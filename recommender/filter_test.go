@@ -0,0 +1,29 @@
+package recommender
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	noAwesome := FilterFunc(func(repo string) bool {
+		return !strings.Contains(strings.ToLower(repo), "awesome")
+	})
+	recs, err := model.Recommend(context.Background(), []string{"sindresorhus/awesome"}, 10, Filters(noAwesome))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of recommendations: %v", recs)
+	}
+	for _, rec := range recs {
+		if !noAwesome(rec.Repository) {
+			t.Errorf("Filtered repository was recommended: %s", rec.Repository)
+		}
+	}
+}
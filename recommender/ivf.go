@@ -0,0 +1,119 @@
+package recommender
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// annMinRepositories is the size from which models are searched with
+	// an index. Smaller ones are scored exhaustively, which is fast enough
+	// and exact.
+	annMinRepositories = 50000
+	// kMeansIterations is how many times the lists of the index are
+	// refined when it is built
+	kMeansIterations = 10
+	// probeFraction is the fraction of the lists searched per query
+	probeFraction = 0.2
+)
+
+// ivfIndex is an inverted file index of the item factors: the items are
+// clustered around centroids, and a query only scores the items of the
+// lists whose centroids match it best. Items are clustered by cosine
+// similarity, with spherical k-means.
+type ivfIndex struct {
+	centroids [][]float64
+	lists     [][]int
+	// the largest norm of the items of each list
+	maxNorms []float64
+	nprobe   int
+}
+
+// newIVFIndex clusters the vectors in about sqrt(n) lists
+func newIVFIndex(vectors [][]float64, norms []float64) *ivfIndex {
+	nLists := int(math.Sqrt(float64(len(vectors))))
+	if nLists < 1 {
+		nLists = 1
+	}
+	unit := make([][]float64, len(vectors))
+	for i, v := range vectors {
+		unit[i] = normalized(v, norms[i])
+	}
+
+	// deterministic initialization, spread over the items
+	centroids := make([][]float64, nLists)
+	for c := range centroids {
+		centroids[c] = append([]float64(nil), unit[c*len(unit)/nLists]...)
+	}
+
+	assignments := make([]int, len(unit))
+	for iteration := 0; iteration < kMeansIterations; iteration++ {
+		for i, v := range unit {
+			assignments[i] = nearest(centroids, v)
+		}
+		sums := make([][]float64, nLists)
+		for c := range sums {
+			sums[c] = make([]float64, len(centroids[c]))
+		}
+		for i, c := range assignments {
+			for k, x := range unit[i] {
+				sums[c][k] += x
+			}
+		}
+		for c, sum := range sums {
+			// empty lists keep their centroid
+			if norm := math.Sqrt(dot(sum, sum)); norm > 0 {
+				centroids[c] = normalized(sum, norm)
+			}
+		}
+	}
+
+	index := &ivfIndex{centroids: centroids, lists: make([][]int, nLists), maxNorms: make([]float64, nLists)}
+	for i, v := range unit {
+		c := nearest(centroids, v)
+		index.lists[c] = append(index.lists[c], i)
+		index.maxNorms[c] = math.Max(index.maxNorms[c], norms[i])
+	}
+	index.nprobe = int(math.Ceil(probeFraction * float64(nLists)))
+	return index
+}
+
+// candidates returns the items of the lists that best match the query.
+// Scores are inner products, which grow with the norm of the items, so
+// lists are ranked by the inner product of their centroid with the query
+// scaled by the largest norm in the list.
+func (ix *ivfIndex) candidates(x []float64) []int {
+	order := make([]documentScore, len(ix.centroids))
+	for c, centroid := range ix.centroids {
+		order[c] = documentScore{c, dot(centroid, x) * ix.maxNorms[c]}
+	}
+	sortScores(order)
+
+	var ids []int
+	for _, c := range order[:ix.nprobe] {
+		ids = append(ids, ix.lists[c.id]...)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func nearest(centroids [][]float64, v []float64) int {
+	best, bestScore := 0, math.Inf(-1)
+	for c, centroid := range centroids {
+		if score := dot(centroid, v); score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+func normalized(v []float64, norm float64) []float64 {
+	u := make([]float64, len(v))
+	if norm == 0 {
+		return u
+	}
+	for k, x := range v {
+		u[k] = x / norm
+	}
+	return u
+}
@@ -0,0 +1,60 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIVFIndexRecall(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	if model.index != nil {
+		t.Fatalf("Small model was indexed")
+	}
+	exact := map[string][]RepositoryScore{}
+	seeds := [][]string{
+		{"tensorflow/tensorflow", "BVLC/caffe"},
+		{"facebook/react", "vuejs/vue", "twbs/bootstrap", "webpack/webpack", "nodejs/node"},
+		{"golang/go"},
+		{"Alamofire/Alamofire", "AFNetworking/AFNetworking"},
+	}
+	for _, items := range seeds {
+		recs, err := model.Recommend(context.Background(), items, 10)
+		if err != nil {
+			t.Fatalf("Failed to recommend: %s", err)
+		}
+		exact[items[0]] = recs
+	}
+
+	model.index = newIVFIndex(model.vectors, model.norms)
+	found, total := 0, 0
+	for _, items := range seeds {
+		recs, err := model.Recommend(context.Background(), items, 10)
+		if err != nil {
+			t.Fatalf("Failed to recommend: %s", err)
+		}
+		same, err := model.Recommend(context.Background(), items, 10, ExactSearch())
+		if err != nil {
+			t.Fatalf("Failed to recommend: %s", err)
+		}
+		if len(same) != 10 || same[0].Repository != exact[items[0]][0].Repository {
+			t.Errorf("Exact search differs: %v", same)
+		}
+		for _, want := range exact[items[0]] {
+			total++
+			for _, rec := range recs {
+				if rec.Repository == want.Repository {
+					found++
+				}
+			}
+		}
+	}
+	// the model is too small for the index to be accurate, but it must
+	// find most recommendations
+	t.Logf("recall@10: %d/%d", found, total)
+	if recall := float64(found) / float64(total); recall < 0.75 {
+		t.Errorf("Index recall is too low: %v", recall)
+	}
+}
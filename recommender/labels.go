@@ -0,0 +1,61 @@
+package recommender
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readLabels reads an optional CSV file of repository,label rows shipped
+// with the model, such as languages.csv, into the lowercased labels of
+// each repository id. A repository may have several rows. Repositories
+// that are not in the model are ignored, and a missing file returns nil.
+func readLabels(filename string, repositoryIDs map[string]int, nRepositories int) ([][]string, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open %s: %v", filename, err)
+	}
+	defer f.Close()
+	return decodeLabels(f, filename, repositoryIDs, nRepositories)
+}
+
+func decodeLabels(r io.Reader, filename string, repositoryIDs map[string]int, nRepositories int) ([][]string, error) {
+	labels := make([][]string, nRepositories)
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read %s: %v", filename, err)
+		}
+		if line == 1 && record[0] == "repository" {
+			continue
+		}
+		id, ok := repositoryIDs[record[0]]
+		label := strings.ToLower(strings.TrimSpace(record[1]))
+		if ok && label != "" {
+			labels[id] = append(labels[id], label)
+		}
+	}
+	return labels, nil
+}
+
+// hasAnyLabel tells whether a repository has any of the wanted labels
+func hasAnyLabel(labels []string, wanted []string) bool {
+	for _, label := range labels {
+		for _, w := range wanted {
+			if label == w {
+				return true
+			}
+		}
+	}
+	return false
+}
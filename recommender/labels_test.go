@@ -0,0 +1,29 @@
+package recommender
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzDecodeLabels(f *testing.F) {
+	f.Add("repository,language\nBVLC/caffe,C++\ntensorflow/tensorflow,C++\n")
+	f.Add("BVLC/caffe,\"Jupyter Notebook\"\nunknown/repository,Go\n")
+	f.Add("BVLC/caffe,C++,extra\n\"unterminated")
+	repositoryIDs := map[string]int{"BVLC/caffe": 0, "tensorflow/tensorflow": 1}
+	f.Fuzz(func(t *testing.T, csv string) {
+		labels, err := decodeLabels(strings.NewReader(csv), "labels.csv", repositoryIDs, len(repositoryIDs))
+		if err != nil {
+			return
+		}
+		if len(labels) != len(repositoryIDs) {
+			t.Fatalf("Wrong number of repositories: %d", len(labels))
+		}
+		for _, repoLabels := range labels {
+			for _, label := range repoLabels {
+				if label == "" || label != strings.ToLower(strings.TrimSpace(label)) {
+					t.Errorf("Label is not normalized: %q", label)
+				}
+			}
+		}
+	})
+}
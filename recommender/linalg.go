@@ -0,0 +1,87 @@
+package recommender
+
+import (
+	"fmt"
+	"math"
+)
+
+// cholesky is the decomposition A = L * L^T of a symmetric positive
+// definite n x n matrix, used to solve the small linear systems of the
+// factorization model without pulling in a matrix library.
+type cholesky struct {
+	n int
+	l []float64 // lower triangular, row-major
+}
+
+// newCholesky decomposes the row-major n x n matrix a
+func newCholesky(a []float64, n int) (*cholesky, error) {
+	l := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := a[i*n+j]
+			for k := 0; k < j; k++ {
+				sum -= l[i*n+k] * l[j*n+k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, fmt.Errorf("Matrix is not positive definite")
+				}
+				l[i*n+i] = math.Sqrt(sum)
+			} else {
+				l[i*n+j] = sum / l[j*n+j]
+			}
+		}
+	}
+	return &cholesky{n, l}, nil
+}
+
+// solve returns x such that A x = b
+func (c *cholesky) solve(b []float64) []float64 {
+	n, l := c.n, c.l
+	// forward substitution for L y = b
+	y := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i*n+k] * y[k]
+		}
+		y[i] = sum / l[i*n+i]
+	}
+	// back substitution for L^T x = y
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < n; k++ {
+			sum -= l[k*n+i] * x[k]
+		}
+		x[i] = sum / l[i*n+i]
+	}
+	return x
+}
+
+// gramian returns Y^T Y for the rows of Y, as a row-major f x f matrix
+func gramian(vectors [][]float64, f int) []float64 {
+	g := make([]float64, f*f)
+	for _, v := range vectors {
+		addOuter(g, v, 1)
+	}
+	return g
+}
+
+// addOuter adds alpha * v v^T to the row-major matrix g
+func addOuter(g []float64, v []float64, alpha float64) {
+	f := len(v)
+	for i := 0; i < f; i++ {
+		for j := 0; j < f; j++ {
+			g[i*f+j] += alpha * v[i] * v[j]
+		}
+	}
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,44 @@
+package recommender
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCholeskySolve(t *testing.T) {
+	a := []float64{
+		4, 12, -16,
+		12, 37, -43,
+		-16, -43, 98,
+	}
+	c, err := newCholesky(a, 3)
+	if err != nil {
+		t.Fatalf("Failed to decompose: %v", err)
+	}
+	want := []float64{1, -2, 3}
+	b := make([]float64, 3)
+	for i := 0; i < 3; i++ {
+		b[i] = dot(a[i*3:(i+1)*3], want)
+	}
+	for i, x := range c.solve(b) {
+		if math.Abs(x-want[i]) > 1e-9 {
+			t.Errorf("Wrong solution: %v, want %v", c.solve(b), want)
+		}
+	}
+}
+
+func TestCholeskyNotPositiveDefinite(t *testing.T) {
+	if _, err := newCholesky([]float64{1, 2, 2, 1}, 2); err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestGramian(t *testing.T) {
+	g := gramian([][]float64{{1, 2}, {3, 4}}, 2)
+	want := []float64{10, 14, 14, 20}
+	for i := range want {
+		if g[i] != want[i] {
+			t.Fatalf("gramian = %v, want %v", g, want)
+		}
+	}
+}
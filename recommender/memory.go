@@ -0,0 +1,86 @@
+package recommender
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	float64Size = 8
+	// approximate per entry costs of the Go runtime structures
+	stringHeaderSize = 16
+	sliceHeaderSize  = 24
+	mapEntrySize     = 48
+	// used to estimate the names before items.csv is read
+	averageNameLength = 24
+)
+
+// estimateMemoryFootprint approximates the heap used by a model with the
+// given shape: the item factors, of factorSize bytes each in the heap, and
+// their norms, the factors x factors Gramian, and the repository names and
+// indexes.
+func estimateMemoryFootprint(nRepositories, nFactors int, nameBytes int64, factorSize int64) int64 {
+	n, f := int64(nRepositories), int64(nFactors)
+	factors := n*f*factorSize + f*f*float64Size + n*(sliceHeaderSize+float64Size)
+	names := nameBytes + n*stringHeaderSize + 2*n*(mapEntrySize+stringHeaderSize)
+	return factors + names
+}
+
+// checkMemoryBudget fails if a model with the given shape is estimated
+// to need more than budget bytes, unless budget is 0
+func checkMemoryBudget(nRepositories, nFactors int, mapped bool, budget int64) error {
+	factorSize := int64(float64Size)
+	if mapped {
+		factorSize = 0
+	}
+	estimate := estimateMemoryFootprint(nRepositories, nFactors, int64(nRepositories*averageNameLength), factorSize)
+	if budget > 0 && estimate > budget {
+		return fmt.Errorf("Model needs about %d bytes, more than the budget of %d bytes", estimate, budget)
+	}
+	return nil
+}
+
+// MemoryFootprint estimates how many bytes of heap the model uses
+func (m *Model) MemoryFootprint() int64 {
+	var nameBytes int64
+	for _, repo := range m.repositories {
+		nameBytes += int64(len(repo))
+	}
+	factorSize := int64(float64Size)
+	switch {
+	case m.quantized != nil:
+		factorSize = 1
+	case m.mapped != nil:
+		factorSize = 0
+	}
+	return estimateMemoryFootprint(len(m.repositories), m.nFactors, nameBytes, factorSize)
+}
+
+// ParseByteSize parses sizes such as "512MB", "2GiB" or "1048576". Units
+// are powers of 1024.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("Invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
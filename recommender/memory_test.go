@@ -0,0 +1,126 @@
+package recommender
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+		err  bool
+	}{
+		{"1048576", 1 << 20, false},
+		{"512MB", 512 << 20, false},
+		{"2GiB", 2 << 30, false},
+		{" 64 kb ", 64 << 10, false},
+		{"100B", 100, false},
+		{"lots", 0, true},
+		{"-1MB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.s)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestModelMemoryBudget(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	footprint := model.MemoryFootprint()
+	if footprint < int64(len(model.repositories)*model.nFactors*8) {
+		t.Errorf("Footprint is smaller than the factors: %d", footprint)
+	}
+	if _, err := ReadModelWithBudget("../data/", footprint/2); err == nil {
+		t.Errorf("Expected model over budget to be refused")
+	}
+	if _, err := ReadModelWithBudget("../data/", footprint*2); err != nil {
+		t.Errorf("Model within budget was refused: %v", err)
+	}
+}
+
+func TestReadMappedModel(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	mapped, err := ReadMappedModel("../data/", 0)
+	if err != nil {
+		t.Fatalf("Unable to map model: %v", err)
+	}
+	defer mapped.Close()
+
+	if mapped.MemoryFootprint() >= model.MemoryFootprint() {
+		t.Errorf("Mapped factors are counted: %d >= %d", mapped.MemoryFootprint(), model.MemoryFootprint())
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	want, _ := model.Recommend(context.Background(), items, 10)
+	got, err := mapped.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Mapped model recommends %v, want %v", got, want)
+	}
+}
+
+func TestParseNpyHeader(t *testing.T) {
+	npy := func(header string) []byte {
+		b := append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), 0)
+		return append(b, header...)
+	}
+	tests := []struct {
+		b          []byte
+		rows, cols int
+		err        bool
+	}{
+		{npy("{'descr': '<f8', 'fortran_order': False, 'shape': (1000, 20), }\n"), 1000, 20, false},
+		{npy("{'descr': '<f4', 'fortran_order': False, 'shape': (1000, 20), }\n"), 0, 0, true},
+		{npy("{'descr': '<f8', 'fortran_order': True, 'shape': (1000, 20), }\n"), 0, 0, true},
+		{npy("{'descr': '<f8', 'fortran_order': False, 'shape': (1000,), }\n"), 0, 0, true},
+		{[]byte("not numpy"), 0, 0, true},
+	}
+	for _, tt := range tests {
+		offset, rows, cols, err := parseNpyHeader(tt.b)
+		if (err != nil) != tt.err || rows != tt.rows || cols != tt.cols {
+			t.Errorf("parseNpyHeader(%q) = %d, %d, %v", tt.b, rows, cols, err)
+		}
+		if err == nil && offset != len(tt.b) {
+			t.Errorf("Wrong offset of the data: %d", offset)
+		}
+	}
+}
+
+func FuzzParseByteSize(f *testing.F) {
+	for _, s := range []string{"1048576", "512MB", "2GiB", " 64 kb ", "-1MB", "9223372036854775807G"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if n, err := ParseByteSize(s); err == nil && n < 0 {
+			t.Errorf("ParseByteSize(%q) = %d", s, n)
+		}
+	})
+}
+
+func FuzzParseNpyHeader(f *testing.F) {
+	valid, err := ioutil.ReadFile("../data/item_factors.npy")
+	if err != nil {
+		f.Fatalf("Unable to read item factors: %v", err)
+	}
+	f.Add(valid[:128])
+	f.Add([]byte("\x93NUMPY\x02\x00\x10\x00\x00\x00{'shape': (1, 2)}"))
+	f.Add([]byte("\x93NUMPY\x01\x00\xff\xff"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		offset, rows, cols, err := parseNpyHeader(b)
+		if err == nil && (offset > len(b) || rows < 0 || cols < 0) {
+			t.Errorf("parseNpyHeader(%q) = %d, %d, %d", b, offset, rows, cols)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package recommender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+var (
+	npyMagic = []byte("\x93NUMPY")
+	npyShape = regexp.MustCompile(`'shape':\s*\((\d+),\s*(\d+),?\)`)
+)
+
+// mappedMatrix is a row-major float64 matrix backed by a read-only memory
+// map of a .npy file. Its pages are read from disk as they are used, are
+// not part of the Go heap, and are shared through the page cache by every
+// process that maps the same file.
+type mappedMatrix struct {
+	data       []float64
+	rows, cols int
+	mapping    []byte
+}
+
+// mapNpy maps a 2-dimensional, little-endian, C-ordered float64 .npy file,
+// the format the item factors are saved in
+func mapNpy(path string) (*mappedMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to map %s: %v", path, err)
+	}
+
+	offset, rows, cols, err := parseNpyHeader(mapping)
+	if err == nil && !nativeLittleEndian() {
+		err = fmt.Errorf("mapping needs a little-endian machine")
+	}
+	if err == nil && (offset%8 != 0 || (cols > 0 && rows > (len(mapping)-offset)/8/cols)) {
+		err = fmt.Errorf("data is misaligned or truncated")
+	}
+	if err != nil {
+		unmapFile(mapping)
+		return nil, fmt.Errorf("Unable to map %s: %v", path, err)
+	}
+
+	m := &mappedMatrix{rows: rows, cols: cols, mapping: mapping}
+	if n := rows * cols; n > 0 {
+		header := (*reflect.SliceHeader)(unsafe.Pointer(&m.data))
+		header.Data = uintptr(unsafe.Pointer(&mapping[offset]))
+		header.Len = n
+		header.Cap = n
+	}
+	return m, nil
+}
+
+func (m *mappedMatrix) close() error {
+	if m.mapping == nil {
+		return nil
+	}
+	mapping := m.mapping
+	m.data, m.mapping = nil, nil
+	return unmapFile(mapping)
+}
+
+// parseNpyHeader returns where the data of a .npy file starts and its
+// shape, failing for anything but 2-dimensional C-ordered '<f8' arrays
+func parseNpyHeader(b []byte) (offset, rows, cols int, err error) {
+	if len(b) < 10 || !bytes.HasPrefix(b, npyMagic) {
+		return 0, 0, 0, fmt.Errorf("not a .npy file")
+	}
+	var headerLen int
+	switch major := b[6]; major {
+	case 1:
+		headerLen, offset = int(binary.LittleEndian.Uint16(b[8:10])), 10
+	case 2, 3:
+		if len(b) < 12 {
+			return 0, 0, 0, fmt.Errorf("not a .npy file")
+		}
+		headerLen, offset = int(binary.LittleEndian.Uint32(b[8:12])), 12
+	default:
+		return 0, 0, 0, fmt.Errorf("unsupported .npy version %d", major)
+	}
+	if len(b) < offset+headerLen {
+		return 0, 0, 0, fmt.Errorf("truncated .npy header")
+	}
+	header := string(b[offset : offset+headerLen])
+	offset += headerLen
+
+	if !strings.Contains(header, "'descr': '<f8'") || !strings.Contains(header, "'fortran_order': False") {
+		return 0, 0, 0, fmt.Errorf("expected a C-ordered '<f8' array: %s", strings.TrimSpace(header))
+	}
+	shape := npyShape.FindStringSubmatch(header)
+	if shape == nil {
+		return 0, 0, 0, fmt.Errorf("expected a 2-dimensional array: %s", strings.TrimSpace(header))
+	}
+	rows, err = strconv.Atoi(shape[1])
+	if err == nil {
+		cols, err = strconv.Atoi(shape[2])
+	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid shape: %v", err)
+	}
+	return offset, rows, cols, nil
+}
+
+func nativeLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package recommender
+
+import (
+	"fmt"
+	"os"
+)
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, fmt.Errorf("memory mapping is not supported on this platform")
+}
+
+func unmapFile(b []byte) error {
+	return nil
+}
@@ -0,0 +1,1000 @@
+// Package recommender recommends GitHub repositories with the item
+// factors of an implicit feedback ALS model, read from a model directory.
+// It has nothing to do with serving them, so other programs can embed it:
+//
+//	model, err := recommender.ReadModel("./data/")
+//	...
+//	recs, err := model.Recommend(ctx, []string{"golang/go"}, 10)
+package recommender
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jbochi/github-recs/lru"
+	"github.com/kshedden/gonpy"
+)
+
+// queryCacheBytes bounds the queries a model keeps, so the factors of
+// users who come back are not solved for again. A query of f factors
+// takes about 8 f^2 bytes.
+const queryCacheBytes = 32 << 20
+
+// cacheHitsKey is the context key of the counter of CountCacheHits
+type cacheHitsKey struct{}
+
+type (
+	// Model is the struct that handles recommendations. It scores
+	// repositories with the item factors of an implicit feedback ALS
+	// model, solving for the factors of each query on the fly.
+	//
+	// A Model is immutable once read, but for its cache of queries: its
+	// methods are safe for concurrent use, and those that derive a
+	// variant, such as Quantized, return a new Model. The only exception
+	// is Close, which must not be called while the model is in use.
+	Model struct {
+		nFactors       int
+		confidence     float64
+		regularization float64
+		// item factors and their norms, by repository id. The factors
+		// are backed by mapped when the model is memory mapped, and nil
+		// when they are quantized.
+		mapped    *mappedMatrix
+		vectors   [][]float64
+		quantized *quantizedFactors
+		norms     []float64
+		// Y^T Y of the item factors, row-major
+		yty           []float64
+		repositories  []string
+		repositoryIDs map[string]int
+		// lowercased entity path -> id, used to resolve user input
+		normalizedIDs map[string]int
+		// lowercased languages and topics by id, nil without
+		// languages.csv and topics.csv
+		languages [][]string
+		topics    [][]string
+		// index of the item factors, nil for small models
+		index *ivfIndex
+		// the canonical id of each repository, and the ids of the alias
+		// sets with more than one entry, by canonical id
+		canonical []int
+		aliases   map[int][]int
+		// how many ids are aliases of another, at most how many entries
+		// merging the aliases in a list of scores can remove
+		redundantAliases int
+		// queries by their sorted items, nil to solve for every one
+		queries *lru.Cache
+	}
+
+	// RepositoryScore is a pair of repo / score
+	RepositoryScore struct {
+		ID         int64   `json:"id"`
+		Repository string  `json:"repository"`
+		Score      float64 `json:"score"`
+		// the items that contributed the most to the score
+		Because []string `json:"because,omitempty"`
+	}
+
+	documentScore struct {
+		id    int
+		score float64
+	}
+
+	// query holds the factors solved for a set of items
+	query struct {
+		items  []int
+		solver *cholesky
+		x      []float64
+	}
+
+	// RepositoryRank is the position of a repository among all the
+	// repositories that could be recommended for a set of items
+	RepositoryRank struct {
+		RepositoryScore
+		Rank  int `json:"rank"`
+		Total int `json:"total"`
+	}
+)
+
+// ReadModel returns a VectorModel from given file path
+func ReadModel(path string) (*Model, error) {
+	return ReadModelWithBudget(path, 0)
+}
+
+// ReadModelWithBudget is like ReadModel, but refuses to load a model whose
+// estimated memory footprint exceeds budget bytes. A budget of 0 means no
+// limit.
+func ReadModelWithBudget(path string, budget int64) (*Model, error) {
+	return readModel(path, budget, formatAuto)
+}
+
+// ReadMappedModel is like ReadModelWithBudget, but memory maps the item
+// factors instead of reading them into the heap, so they are paged in
+// from disk as they are used and instances on the same host share them
+// through the page cache. The budget does not count them. They are
+// unmapped when the model is garbage collected, or closed.
+func ReadMappedModel(path string, budget int64) (*Model, error) {
+	return readModel(path, budget, formatMapped)
+}
+
+// modelFormat is how the item factors and names of a model are stored
+type modelFormat int
+
+const (
+	// formatAuto is formatBinary if there is a binary model file, and
+	// formatNpy otherwise
+	formatAuto modelFormat = iota
+	// formatNpy is item_factors.npy and items.csv, as trained
+	formatNpy
+	// formatMapped is formatNpy with the factors memory mapped
+	formatMapped
+	// formatBinary is the binary model file made by ConvertModel
+	formatBinary
+)
+
+func readModel(path string, budget int64, format modelFormat) (m *Model, err error) {
+	if format == formatAuto {
+		format = formatNpy
+		if _, err := os.Stat(path + binaryModelFile); err == nil {
+			format = formatBinary
+		}
+	}
+
+	var matrix *mappedMatrix
+	var data []float64
+	var repositories []string
+	var nFactors int
+	switch format {
+	case formatBinary:
+		data, repositories, nFactors, err = readBinaryModel(path+binaryModelFile, budget)
+		if err != nil {
+			return nil, err
+		}
+	case formatMapped:
+		matrix, err = mapNpy(path + "item_factors.npy")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				matrix.close()
+			}
+		}()
+		if err := checkMemoryBudget(matrix.rows, matrix.cols, true, budget); err != nil {
+			return nil, err
+		}
+		data, nFactors = matrix.data, matrix.cols
+		repositories, err = readItems(path+"items.csv", matrix.rows)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		rdr, err := gonpy.NewFileReader(path + "item_factors.npy")
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read data: %v", err)
+		}
+		if err := checkMemoryBudget(rdr.Shape[0], rdr.Shape[1], false, budget); err != nil {
+			return nil, err
+		}
+		data, err = rdr.GetFloat64()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse data: %v", err)
+		}
+		nFactors = rdr.Shape[1]
+		repositories, err = readItems(path+"items.csv", rdr.Shape[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	m = newModel(data, nFactors, repositories)
+	if matrix != nil {
+		m.mapped = matrix
+		// unmapped once unused, e.g. after a reload
+		runtime.SetFinalizer(m, (*Model).Close)
+	}
+	labels, err := readModelLabels(path, m.repositoryIDs, len(repositories))
+	if err != nil {
+		return nil, err
+	}
+	m.setLabels(labels)
+	return m, nil
+}
+
+// modelLabels are the optional files of a model that label its
+// repositories, by id
+type modelLabels struct {
+	languages, topics, aliases [][]string
+}
+
+func readModelLabels(path string, repositoryIDs map[string]int, n int) (labels modelLabels, err error) {
+	labels.languages, err = readLabels(path+"languages.csv", repositoryIDs, n)
+	if err == nil {
+		labels.topics, err = readLabels(path+"topics.csv", repositoryIDs, n)
+	}
+	if err == nil {
+		labels.aliases, err = readLabels(path+"aliases.csv", repositoryIDs, n)
+	}
+	return labels, err
+}
+
+// setLabels sets the labels of the repositories of the model, which may
+// be the first ones of those labeled
+func (m *Model) setLabels(labels modelLabels) {
+	n := len(m.repositories)
+	if labels.languages != nil {
+		m.languages = labels.languages[:n]
+	}
+	if labels.topics != nil {
+		m.topics = labels.topics[:n]
+	}
+	if labels.aliases != nil {
+		m.setAliases(labels.aliases[:n])
+	}
+}
+
+// newModel returns a model of the given item factors, row-major, and
+// names, without labels or aliases
+func newModel(data []float64, nFactors int, repositories []string) *Model {
+	b := newModelBuilder(nFactors, repositories)
+	b.add(data[:len(repositories)*nFactors])
+	return b.model()
+}
+
+// modelBuilder builds a model a shard of its factors at a time. The names
+// of all the repositories are known from the start, and each shard only
+// updates the norms and the Gram matrix with its own rows.
+type modelBuilder struct {
+	nFactors      int
+	repositories  []string
+	repositoryIDs map[string]int
+	normalizedIDs map[string]int
+	vectors       [][]float64
+	norms         []float64
+	yty           []float64
+}
+
+func newModelBuilder(nFactors int, repositories []string) *modelBuilder {
+	b := &modelBuilder{
+		nFactors:      nFactors,
+		repositories:  repositories,
+		repositoryIDs: map[string]int{},
+		normalizedIDs: map[string]int{},
+		vectors:       make([][]float64, 0, len(repositories)),
+		norms:         make([]float64, 0, len(repositories)),
+		yty:           make([]float64, nFactors*nFactors),
+	}
+	for i, repo := range repositories {
+		b.repositoryIDs[repo] = i
+		b.normalizedIDs[strings.ToLower(repo)] = i
+	}
+	return b
+}
+
+// add adds the rows of data after the ones added so far, data being all
+// the factors read so far
+func (b *modelBuilder) add(data []float64) {
+	f := b.nFactors
+	for i := len(b.vectors); (i+1)*f <= len(data); i++ {
+		v := data[i*f : (i+1)*f]
+		b.vectors = append(b.vectors, v)
+		b.norms = append(b.norms, math.Sqrt(dot(v, v)))
+		addOuter(b.yty, v, 1)
+	}
+}
+
+// model returns a model of the rows added so far, which adding more rows
+// does not change. Only the model of all the repositories gets an index:
+// partial ones, which are replaced soon, score every repository.
+func (b *modelBuilder) model() *Model {
+	n := len(b.vectors)
+	m := &Model{
+		nFactors:       b.nFactors,
+		confidence:     3.0,
+		regularization: 0.001,
+		vectors:        b.vectors[:n:n],
+		norms:          b.norms[:n:n],
+		yty:            append([]float64(nil), b.yty...),
+		repositories:   b.repositories[:n:n],
+		repositoryIDs:  b.repositoryIDs,
+		normalizedIDs:  b.normalizedIDs,
+		queries:        lru.New(queryCacheBytes),
+	}
+	if n == len(b.repositories) && n >= annMinRepositories {
+		m.index = newIVFIndex(m.vectors, m.norms)
+	}
+	m.setAliases(nil)
+	return m
+}
+
+// readItems reads the names of the n repositories of a model, one per line
+func readItems(filename string, n int) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open items.csv: %v", err)
+	}
+	defer f.Close()
+
+	repositories := make([]string, n)
+	reader := bufio.NewReader(f)
+	for i := range repositories {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read line of file: %v", err)
+		}
+		repositories[i] = strings.TrimRight(line, "\n")
+	}
+	return repositories, nil
+}
+
+// Close releases the memory map of a mapped model, after which it must not
+// be used. It does nothing for other models.
+func (m *Model) Close() error {
+	if m.mapped == nil {
+		return nil
+	}
+	return m.mapped.close()
+}
+
+// RepositoryItemID returns a stable numeric id for a repository. It is
+// derived from the lowercased full name alone, so it does not change
+// between model versions, and it fits in 53 bits so JSON clients can
+// represent it exactly.
+func RepositoryItemID(repo string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(repo)))
+	return int64(h.Sum64() & (1<<53 - 1))
+}
+
+// RepositoryID resolves a repository reference to its id in the model.
+// Full names are matched exactly first; anything else is normalized and
+// matched case-insensitively against the longest known entity path, so a
+// deep link into a monorepo resolves to the sub-path entity when the
+// vocabulary has one and to the repository otherwise.
+func (m *Model) RepositoryID(ref string) (int, bool) {
+	// the lookups of a partial model list the repositories not read yet
+	if id, ok := m.repositoryIDs[ref]; ok && id < len(m.repositories) {
+		return id, true
+	}
+	parts := strings.Split(strings.ToLower(NormalizeRepository(ref)), "/")
+	for i := len(parts); i >= 2; i-- {
+		if id, ok := m.normalizedIDs[strings.Join(parts[:i], "/")]; ok && id < len(m.repositories) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Repository returns the canonical entity path for a reference, or an
+// empty string if it is not part of the model.
+func (m *Model) Repository(ref string) string {
+	id, ok := m.RepositoryID(ref)
+	if !ok {
+		return ""
+	}
+	return m.canonicalName(id)
+}
+
+// Repositories returns the repositories of the model, by id, including
+// the aliases of others
+func (m *Model) Repositories() []string {
+	return append([]string(nil), m.repositories...)
+}
+
+// NumRepositories returns how many repositories the model has, like
+// len(m.Repositories()) without the copy
+func (m *Model) NumRepositories() int {
+	return len(m.repositories)
+}
+
+// Known returns how many distinct repositories of the model the items
+// resolve to
+func (m *Model) Known(items []string) int {
+	return len(m.seenDocs(items))
+}
+
+// Labels returns the lowercased languages and topics of a repository, nil
+// if the model has none or does not know it. They are shared with the
+// model, and must not be modified.
+func (m *Model) Labels(repo string) (languages, topics []string) {
+	id, ok := m.RepositoryID(repo)
+	if !ok {
+		return nil, nil
+	}
+	if m.languages != nil {
+		languages = m.languages[id]
+	}
+	if m.topics != nil {
+		topics = m.topics[id]
+	}
+	return languages, topics
+}
+
+// Factors returns the number of factors of the model
+func (m *Model) Factors() int {
+	return m.nFactors
+}
+
+// Confidence returns the weight of an item in a query, against 1 for the
+// repositories that are not
+func (m *Model) Confidence() float64 {
+	return m.confidence
+}
+
+// Indexed tells whether candidates are searched in an index, rather than
+// by scoring every repository
+func (m *Model) Indexed() bool {
+	return m.index != nil
+}
+
+// QueryCacheStats returns the stats of the cache of queries of the model
+func (m *Model) QueryCacheStats() lru.Stats {
+	return m.queries.Stats()
+}
+
+// setAliases groups the entries of the vocabulary that are the same
+// repository: names that only differ in case, as GitHub names are case
+// insensitive, and the renames listed in aliases.csv (rows of
+// repository,canonical). The canonical entry of a case-insensitive name
+// is its last one in items.csv, which is the one user input resolves to.
+func (m *Model) setAliases(renames [][]string) {
+	m.canonical = make([]int, len(m.repositories))
+	for id, repo := range m.repositories {
+		m.canonical[id] = id
+		if c, ok := m.normalizedIDs[strings.ToLower(repo)]; ok && c < len(m.repositories) {
+			m.canonical[id] = c
+		}
+	}
+	for id, labels := range renames {
+		for _, name := range labels {
+			if c, ok := m.RepositoryID(name); ok {
+				m.canonical[id] = c
+			}
+		}
+	}
+	// follow chains of renames, stopping at cycles
+	for id := range m.canonical {
+		c := id
+		for steps := 0; m.canonical[c] != c && steps < len(m.canonical); steps++ {
+			c = m.canonical[c]
+		}
+		m.canonical[id] = c
+	}
+
+	sets := map[int][]int{}
+	for id, c := range m.canonical {
+		sets[c] = append(sets[c], id)
+	}
+	m.aliases = map[int][]int{}
+	m.redundantAliases = 0
+	for c, ids := range sets {
+		if len(ids) > 1 {
+			m.aliases[c] = ids
+			m.redundantAliases += len(ids) - 1
+		}
+	}
+}
+
+func (m *Model) canonicalName(id int) string {
+	return m.repositories[m.canonical[id]]
+}
+
+// withAliases adds the aliases of the repositories in the set to it
+func (m *Model) withAliases(set map[int]bool) map[int]bool {
+	for id := range set {
+		for _, alias := range m.aliases[m.canonical[id]] {
+			set[alias] = true
+		}
+	}
+	return set
+}
+
+// mergeAliases keeps the best score of each alias set, so the same
+// repository cannot take several places. The order is kept.
+func (m *Model) mergeAliases(scores []documentScore) []documentScore {
+	if len(m.aliases) == 0 {
+		return scores
+	}
+	merged := scores[:0]
+	best := map[int]int{}
+	for _, s := range scores {
+		c := m.canonical[s.id]
+		if i, ok := best[c]; ok {
+			if ranksBefore(s, merged[i]) {
+				merged[i] = s
+			}
+			continue
+		}
+		best[c] = len(merged)
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// cancelCheckInterval is how many repositories are scored between checks
+// for the cancellation of a context
+const cancelCheckInterval = 1024
+
+// CountCacheHits returns a copy of ctx in which the queries that
+// Recommend finds in the cache of the model are counted in hits, which is
+// updated atomically.
+func CountCacheHits(ctx context.Context, hits *int32) context.Context {
+	return context.WithValue(ctx, cacheHitsKey{}, hits)
+}
+
+// Recommend returns a list of recommended repositories. It stops scoring,
+// and returns the error of ctx, if ctx is done first.
+func (m *Model) Recommend(ctx context.Context, items []string, n int, opts ...RecommendOption) ([]RepositoryScore, error) {
+	if err := checkN(n); err != nil {
+		return nil, err
+	}
+	o, err := m.newRecommendOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	seenDocs := m.seenDocs(items)
+	excluded := m.seenDocs(o.excludeRepositories)
+	if !o.includeItems {
+		for id := range seenDocs {
+			excluded[id] = true
+		}
+	}
+	excluded = m.withAliases(excluded)
+	q, err := m.newQuery(ctx, seenDocs)
+	if err != nil {
+		return nil, err
+	}
+
+	// filters are applied before the top n cut, which keeps enough
+	// candidates for the aliases to be merged and for re-ranking
+	k := n
+	if o.lambda < 1 {
+		k = n * mmrPoolSize
+	}
+	k += m.redundantAliases
+	var candidates []documentScore
+	if m.index != nil && !o.exact {
+		candidates, err = m.score(ctx, q, m.index.candidates(q.x), excluded, o, k)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the lists searched may not have enough candidates left after
+	// filtering, in which case all repositories are scored
+	if len(candidates) < n {
+		candidates, err = m.score(ctx, q, nil, excluded, o, k)
+		if err != nil {
+			return nil, err
+		}
+	}
+	candidates = m.mergeAliases(candidates)
+	if o.lambda < 1 {
+		candidates = m.mmr(candidates, n, o.lambda)
+	}
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	results := []RepositoryScore{}
+	for _, score := range candidates {
+		repo := m.canonicalName(score.id)
+		result := RepositoryScore{RepositoryItemID(repo), repo, score.score, m.because(q, score.id)}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// score scores the given repositories, or all of them if ids is nil,
+// leaving out the excluded ones and those the options filter out. It
+// returns the k best scores, or all of them if k is 0, best first.
+func (m *Model) score(ctx context.Context, q *query, ids []int, excluded map[int]bool, o *recommendOptions, k int) ([]documentScore, error) {
+	n := len(ids)
+	if ids == nil {
+		n = len(m.repositories)
+	}
+	var scores []documentScore
+	var top *topScores
+	// quantized models select candidates in integer arithmetic, and only
+	// score those exactly
+	quantized := m.quantized != nil && k > 0
+	var codes []int8
+	var scale float64
+	if quantized {
+		codes = make([]int8, m.nFactors)
+		scale = quantizeVector(q.x, codes)
+		top = newTopScores(k * quantizedRescoreFactor)
+	} else if k > 0 {
+		top = newTopScores(k)
+	}
+	for i := 0; i < n; i++ {
+		id := i
+		if ids != nil {
+			id = ids[i]
+		}
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if excluded[id] || !m.allowed(o, id) {
+			continue
+		}
+		switch {
+		case quantized:
+			top.offer(documentScore{id, m.quantized.dot(id, codes, scale)})
+		case top != nil:
+			top.offer(documentScore{id, dot(m.vectors[id], q.x)})
+		default:
+			scores = append(scores, documentScore{id, dot(m.vector(id), q.x)})
+		}
+	}
+	if top == nil {
+		sortScores(scores)
+		return scores, nil
+	}
+	scores = top.sorted()
+	if quantized {
+		for i, s := range scores {
+			scores[i].score = dot(m.quantized.vector(s.id), q.x)
+		}
+		sortScores(scores)
+		if len(scores) > k {
+			scores = scores[:k]
+		}
+	}
+	return scores, nil
+}
+
+// Rank returns the 1-based rank and score of a repository among all the
+// recommendations Recommend makes for the given items with the same
+// options, scoring every repository and without re-ranking them for
+// diversity. It fails if the repository is not part of the model, and the
+// rank is 0 if the repository is left out, as the items and those the
+// options filter out are.
+func (m *Model) Rank(ctx context.Context, items []string, repo string, opts ...RecommendOption) (RepositoryRank, error) {
+	repoID, ok := m.RepositoryID(repo)
+	if !ok {
+		return RepositoryRank{}, fmt.Errorf("Unknown repository: %s", repo)
+	}
+	o, err := m.newRecommendOptions(opts)
+	if err != nil {
+		return RepositoryRank{}, err
+	}
+	seenDocs := m.seenDocs(items)
+	excluded := m.seenDocs(o.excludeRepositories)
+	if !o.includeItems {
+		for id := range seenDocs {
+			excluded[id] = true
+		}
+	}
+	q, err := m.newQuery(ctx, seenDocs)
+	if err != nil {
+		return RepositoryRank{}, err
+	}
+	scores, err := m.score(ctx, q, nil, m.withAliases(excluded), o, 0)
+	if err != nil {
+		return RepositoryRank{}, err
+	}
+	scores = m.mergeAliases(scores)
+
+	rank := RepositoryRank{}
+	rank.Repository = m.canonicalName(repoID)
+	rank.ID = RepositoryItemID(rank.Repository)
+	rank.Total = len(scores)
+	for i, score := range scores {
+		if m.canonical[score.id] == m.canonical[repoID] {
+			rank.Rank = i + 1
+			rank.Score = score.score
+			rank.Because = m.because(q, score.id)
+		}
+	}
+	return rank, nil
+}
+
+// newQuery solves for the factors of a user who interacted with the
+// given items: x = (Y^T C Y + reg I)^-1 Y^T C p, where the confidence C is
+// the model confidence for the items and 1 for everything else. Queries
+// are cached, and must not be modified. Cache hits are counted in the
+// counter of ctx, if any.
+func (m *Model) newQuery(ctx context.Context, seenDocs map[int]bool) (*query, error) {
+	f := m.nFactors
+	a := make([]float64, f*f)
+	copy(a, m.yty)
+	b := make([]float64, f)
+
+	// sorted, so that floating point sums do not depend on map order
+	items := make([]int, 0, len(seenDocs))
+	for id := range seenDocs {
+		items = append(items, id)
+	}
+	sort.Ints(items)
+	key := fmt.Sprint(items)
+	if cached, ok := m.queries.Get(key); ok {
+		if hits, ok := ctx.Value(cacheHitsKey{}).(*int32); ok {
+			atomic.AddInt32(hits, 1)
+		}
+		return cached.(*query), nil
+	}
+	for _, id := range items {
+		vector := m.vector(id)
+		addOuter(a, vector, m.confidence-1)
+		for k, v := range vector {
+			b[k] += m.confidence * v
+		}
+	}
+	for i := 0; i < f; i++ {
+		a[i*f+i] += m.regularization
+	}
+
+	solver, err := newCholesky(a, f)
+	if err != nil {
+		return nil, err
+	}
+	q := &query{items, solver, solver.solve(b)}
+	m.queries.Add(key, q, int64(len(items)+f*f+f)*float64Size)
+	return q, nil
+}
+
+// because returns the (at most two) items of the query that contributed
+// the most to the score of a repository. The score y_i^T x splits into
+// one term per item j: confidence * y_i^T A^-1 y_j.
+func (m *Model) because(q *query, id int) []string {
+	if len(q.items) == 0 {
+		return nil
+	}
+	z := q.solver.solve(m.vector(id))
+	var contributions []documentScore
+	for _, item := range q.items {
+		if c := m.confidence * dot(z, m.vector(item)); c > 0 {
+			contributions = append(contributions, documentScore{item, c})
+		}
+	}
+	sortScores(contributions)
+	var because []string
+	for i := 0; i < len(contributions) && i < 2; i++ {
+		because = append(because, m.canonicalName(contributions[i].id))
+	}
+	return because
+}
+
+// mmrPoolSize is how many of the most relevant candidates, per
+// recommendation, MMR picks from
+const mmrPoolSize = 10
+
+// mmr picks n of the sorted candidates by maximal marginal relevance.
+// Relevance is the score relative to the best one, and similarity is the
+// cosine similarity of the factors, so both are on the same scale.
+func (m *Model) mmr(candidates []documentScore, n int, lambda float64) []documentScore {
+	pool := candidates
+	if len(pool) > n*mmrPoolSize {
+		pool = pool[:n*mmrPoolSize]
+	}
+	if len(pool) == 0 || pool[0].score <= 0 {
+		return candidates
+	}
+	best := pool[0].score
+	picked := make([]bool, len(pool))
+	// the highest similarity of each candidate to the picks so far
+	similarity := make([]float64, len(pool))
+	var result []documentScore
+	pick := func(next int) {
+		picked[next] = true
+		result = append(result, pool[next])
+		for i, c := range pool {
+			if s := m.cosine(c.id, pool[next].id); len(result) == 1 || s > similarity[i] {
+				similarity[i] = s
+			}
+		}
+	}
+
+	pick(0)
+	for len(result) < n && len(result) < len(pool) {
+		next, nextValue := -1, 0.0
+		for i, c := range pool {
+			if picked[i] {
+				continue
+			}
+			if value := lambda*c.score/best - (1-lambda)*similarity[i]; next < 0 || value > nextValue {
+				next, nextValue = i, value
+			}
+		}
+		pick(next)
+	}
+	return result
+}
+
+func (m *Model) cosine(a, b int) float64 {
+	if m.norms[a] == 0 || m.norms[b] == 0 {
+		return 0
+	}
+	if m.quantized != nil {
+		return m.quantized.dot(a, m.quantized.row(b), m.quantized.scales[b]) / (m.norms[a] * m.norms[b])
+	}
+	return dot(m.vectors[a], m.vectors[b]) / (m.norms[a] * m.norms[b])
+}
+
+// ranksBefore orders scores from highest to lowest, breaking ties by id
+// so rankings are deterministic.
+func ranksBefore(a, b documentScore) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.id < b.id
+}
+
+func sortScores(scores []documentScore) {
+	sort.Slice(scores, func(i, j int) bool {
+		return ranksBefore(scores[i], scores[j])
+	})
+}
+
+// scoreHeap is a min-heap of scores by ranksBefore: its root is the score
+// that ranks last
+type scoreHeap []documentScore
+
+func (h scoreHeap) Len() int            { return len(h) }
+func (h scoreHeap) Less(i, j int) bool  { return ranksBefore(h[j], h[i]) }
+func (h scoreHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoreHeap) Push(x interface{}) { *h = append(*h, x.(documentScore)) }
+func (h *scoreHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// topScores keeps the k best of the scores offered to it, in O(log k) per
+// score and without allocating past the first k.
+type topScores struct {
+	k    int
+	heap scoreHeap
+}
+
+func newTopScores(k int) *topScores {
+	return &topScores{k: k, heap: make(scoreHeap, 0, k)}
+}
+
+func (t *topScores) offer(score documentScore) {
+	switch {
+	case len(t.heap) < t.k:
+		// the heap is only ordered once it is full
+		t.heap = append(t.heap, score)
+		if len(t.heap) == t.k {
+			heap.Init(&t.heap)
+		}
+	case t.k > 0 && ranksBefore(score, t.heap[0]):
+		t.heap[0] = score
+		heap.Fix(&t.heap, 0)
+	}
+}
+
+// sorted returns the scores kept, best first. Nothing can be offered
+// after it is called.
+func (t *topScores) sorted() []documentScore {
+	sortScores(t.heap)
+	return t.heap
+}
+
+// Similar returns the n repositories most similar to the given one, by
+// the cosine similarity of their factors.
+func (m *Model) Similar(repo string, n int, opts ...RecommendOption) ([]RepositoryScore, error) {
+	if err := checkN(n); err != nil {
+		return nil, err
+	}
+	o, err := m.newRecommendOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	repoID, ok := m.RepositoryID(repo)
+	if !ok {
+		return nil, fmt.Errorf("Unknown repository: %s", repo)
+	}
+	excluded := m.seenDocs(o.excludeRepositories)
+	excluded[repoID] = true
+	excluded = m.withAliases(excluded)
+
+	top := newTopScores(n + m.redundantAliases)
+	for id := range m.repositories {
+		if excluded[id] || !m.allowed(o, id) {
+			continue
+		}
+		top.offer(documentScore{id, m.cosine(repoID, id)})
+	}
+	return m.repositoryScores(top.sorted(), n), nil
+}
+
+// checkN checks the number of results asked of the model
+func checkN(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("Invalid number of results %d, expected at least 1", n)
+	}
+	return nil
+}
+
+// repositoryScores returns the first n of the sorted candidates, once
+// their aliases are merged, by their canonical names
+func (m *Model) repositoryScores(candidates []documentScore, n int) []RepositoryScore {
+	candidates = m.mergeAliases(candidates)
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	var scores []RepositoryScore
+	for _, score := range candidates {
+		name := m.canonicalName(score.id)
+		scores = append(scores, RepositoryScore{ID: RepositoryItemID(name), Repository: name, Score: score.score})
+	}
+	return scores
+}
+
+// newRecommendOptions applies the options, failing if they need data the
+// model was loaded without
+func (m *Model) newRecommendOptions(opts []RecommendOption) (*recommendOptions, error) {
+	o, err := newRecommendOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.languages) > 0 && m.languages == nil {
+		return nil, fmt.Errorf("Language data is not available for this model")
+	}
+	if len(o.topics) > 0 && m.topics == nil {
+		return nil, fmt.Errorf("Topic data is not available for this model")
+	}
+	return o, nil
+}
+
+// allowed tells whether a repository passes the filters of the options
+func (m *Model) allowed(o *recommendOptions, id int) bool {
+	if len(o.languages) > 0 && !hasAnyLabel(m.languages[id], o.languages) {
+		return false
+	}
+	if len(o.topics) > 0 && !hasAnyLabel(m.topics[id], o.topics) {
+		return false
+	}
+	return o.allowed(m.repositories[id])
+}
+
+// Excluded explains why the options leave a repository of the model out
+// of the recommendations, e.g. `it matches the exclude pattern "apache/*"`,
+// or returns "" when they keep it. Stars left out with ExcludeRepositories
+// are not reported.
+func (m *Model) Excluded(repo string, opts ...RecommendOption) (string, error) {
+	o, err := m.newRecommendOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	id, ok := m.RepositoryID(repo)
+	if !ok {
+		return "", fmt.Errorf("Unknown repository: %s", repo)
+	}
+	if len(o.languages) > 0 && !hasAnyLabel(m.languages[id], o.languages) {
+		return fmt.Sprintf("it is in none of the languages %s", strings.Join(o.languages, ", ")), nil
+	}
+	if len(o.topics) > 0 && !hasAnyLabel(m.topics[id], o.topics) {
+		return fmt.Sprintf("it has none of the topics %s", strings.Join(o.topics, ", ")), nil
+	}
+	if pattern, ok := o.excludedBy(m.repositories[id]); ok {
+		return fmt.Sprintf("it matches the exclude pattern %q", pattern), nil
+	}
+	if !o.filtered(m.repositories[id]) {
+		return "it is hidden by a filter", nil
+	}
+	return "", nil
+}
+
+func (m *Model) seenDocs(items []string) map[int]bool {
+	seenDocs := map[int]bool{}
+	for _, repo := range items {
+		repoID, ok := m.RepositoryID(repo)
+		if ok {
+			seenDocs[repoID] = true
+		}
+	}
+	return seenDocs
+}
@@ -0,0 +1,275 @@
+package recommender
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestModel(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	if model == nil {
+		t.Fatalf("Did not return a model")
+	}
+	recs, err := model.Recommend(context.Background(), []string{"tensorflow/tensorflow", "BVLC/caffe"}, 10)
+	if err != nil {
+		t.Errorf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of recommendations: %v", recs)
+	}
+}
+
+func TestRank(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	recs, err := model.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	rank, err := model.Rank(context.Background(), items, recs[2].Repository)
+	if err != nil {
+		t.Fatalf("Failed to rank: %s", err)
+	}
+	if rank.Rank != 3 || rank.Score != recs[2].Score {
+		t.Errorf("Wrong rank for %s: %+v", recs[2].Repository, rank)
+	}
+	rank, err = model.Rank(context.Background(), items, recs[2].Repository, ExcludeRepositories(recs[0].Repository))
+	if err != nil || rank.Rank != 2 {
+		t.Errorf("Wrong rank for %s without %s: %+v %v", recs[2].Repository, recs[0].Repository, rank, err)
+	}
+	rank, err = model.Rank(context.Background(), items, recs[2].Repository, ExcludeRepositories(recs[2].Repository))
+	if err != nil || rank.Rank != 0 {
+		t.Errorf("Excluded %s was ranked: %+v %v", recs[2].Repository, rank, err)
+	}
+	if _, err := model.Rank(context.Background(), items, "unknown/repository"); err == nil {
+		t.Errorf("Expected error for unknown repository")
+	}
+}
+
+func TestBecause(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe", "golang/go"}
+	recs, err := model.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	for _, rec := range recs {
+		if len(rec.Because) == 0 || len(rec.Because) > 2 {
+			t.Errorf("Wrong explanation for %s: %v", rec.Repository, rec.Because)
+		}
+	}
+
+	// the contributions of the items add up to the score
+	q, err := model.newQuery(context.Background(), model.seenDocs(items))
+	if err != nil {
+		t.Fatalf("Failed to solve query: %s", err)
+	}
+	id, _ := model.RepositoryID(recs[0].Repository)
+	z := q.solver.solve(model.vectors[id])
+	sum := 0.0
+	for _, item := range q.items {
+		sum += model.confidence * dot(z, model.vectors[item])
+	}
+	if math.Abs(sum-recs[0].Score) > 1e-9 {
+		t.Errorf("Contributions add up to %v, not to the score %v", sum, recs[0].Score)
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	first, err := model.newQuery(context.Background(), model.seenDocs([]string{"golang/go", "BVLC/caffe"}))
+	if err != nil {
+		t.Fatalf("Failed to solve query: %s", err)
+	}
+	second, err := model.newQuery(context.Background(), model.seenDocs([]string{"BVLC/caffe", "golang/go"}))
+	if err != nil {
+		t.Fatalf("Failed to solve query: %s", err)
+	}
+	if first != second {
+		t.Errorf("Query of the same items was solved again")
+	}
+	if s := model.queries.Stats(); s.Entries != 1 || s.Hits != 1 || s.Bytes > queryCacheBytes {
+		t.Errorf("Unexpected query cache stats: %+v", s)
+	}
+	if model.Quantized().queries == model.queries {
+		t.Errorf("Quantized model shares the queries of the original")
+	}
+}
+
+func TestRepositoryItemID(t *testing.T) {
+	id := RepositoryItemID("tensorflow/tensorflow")
+	if id != RepositoryItemID("TensorFlow/TensorFlow") {
+		t.Errorf("Item id depends on case")
+	}
+	if id == RepositoryItemID("BVLC/caffe") {
+		t.Errorf("Different repositories have the same id")
+	}
+	if id < 0 || id >= 1<<53 {
+		t.Errorf("Item id out of range: %d", id)
+	}
+}
+
+func BenchmarkModel(b *testing.B) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		b.Fatalf("Unable to read model: %v", err)
+	}
+	if model == nil {
+		b.Fatalf("Did not return a model")
+	}
+	var recs []RepositoryScore
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recs, err = model.Recommend(context.Background(), []string{"tensorflow/tensorflow", "BVLC/caffe"}, 10)
+	}
+
+	if err != nil {
+		b.Errorf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 10 {
+		b.Errorf("Wrong number of recommendations: %v", recs)
+	}
+}
+
+func TestRecommendCancelled(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := model.Recommend(ctx, []string{"BVLC/caffe"}, 10); err != context.Canceled {
+		t.Errorf("Recommend did not stop when cancelled: %v", err)
+	}
+}
+
+func TestInvalidN(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	for _, n := range []int{0, -1} {
+		if _, err := model.Recommend(context.Background(), []string{"BVLC/caffe"}, n); err == nil {
+			t.Errorf("Expected an error to recommend %d", n)
+		}
+		if _, err := model.Similar("BVLC/caffe", n); err == nil {
+			t.Errorf("Expected an error for %d similar repositories", n)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	// not a real rename, but both are in items.csv
+	model := readModelWith(t, map[string]string{
+		"aliases.csv": "repository,canonical\nerikras/redux-form,reactjs/react-redux\n",
+	})
+	// items.csv has both FreeCodeCamp/FreeCodeCamp and the current
+	// freeCodeCamp/freeCodeCamp
+	for _, ref := range []string{"FreeCodeCamp/FreeCodeCamp", "freecodecamp/freecodecamp"} {
+		if got := model.Repository(ref); got != "freeCodeCamp/freeCodeCamp" {
+			t.Errorf("Repository(%q) = %q", ref, got)
+		}
+	}
+	if got := model.Repository("erikras/redux-form"); got != "reactjs/react-redux" {
+		t.Errorf("Rename was not applied: %q", got)
+	}
+
+	recs, err := model.Recommend(context.Background(), []string{"twbs/bootstrap"}, len(model.repositories))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	seen := map[string]bool{}
+	for _, rec := range recs {
+		if seen[rec.Repository] || rec.Repository == "FreeCodeCamp/FreeCodeCamp" || rec.Repository == "erikras/redux-form" {
+			t.Errorf("Alias was recommended: %s", rec.Repository)
+		}
+		seen[rec.Repository] = true
+	}
+
+	recs, err = model.Recommend(context.Background(), []string{"freeCodeCamp/freeCodeCamp"}, len(model.repositories))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	for _, rec := range recs {
+		if rec.Repository == "freeCodeCamp/freeCodeCamp" {
+			t.Errorf("Starred repository was recommended through its alias")
+		}
+	}
+}
+
+func TestTopScores(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var scores []documentScore
+	for id := 0; id < 1000; id++ {
+		// few distinct scores, so ties are broken by id
+		scores = append(scores, documentScore{id, float64(rng.Intn(50))})
+	}
+	for _, k := range []int{0, 1, 10, 999, 1000, 2000} {
+		top := newTopScores(k)
+		for _, s := range scores {
+			top.offer(s)
+		}
+		want := append([]documentScore(nil), scores...)
+		sortScores(want)
+		if k < len(want) {
+			want = want[:k]
+		}
+		got := top.sorted()
+		if len(got) != len(want) {
+			t.Fatalf("Wrong number of top %d scores: %d", k, len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Wrong top %d score at %d: %v, want %v", k, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkTopScores compares selecting the top 10 of a large vocabulary
+// with a bounded heap against sorting all the scores
+func BenchmarkTopScores(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	scores := make([]documentScore, 1000000)
+	for id := range scores {
+		scores[id] = documentScore{id, rng.Float64()}
+	}
+
+	b.Run("sort", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var all []documentScore
+			for _, s := range scores {
+				all = append(all, s)
+			}
+			sortScores(all)
+			_ = all[:10]
+		}
+	})
+	b.Run("heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			top := newTopScores(10)
+			for _, s := range scores {
+				top.offer(s)
+			}
+			_ = top.sorted()
+		}
+	})
+}
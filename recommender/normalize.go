@@ -0,0 +1,52 @@
+package recommender
+
+import (
+	"strings"
+)
+
+// NormalizeRepository turns a repository reference such as
+// "https://github.com/owner/repo/tree/master/some/dir" or
+// "github.com/owner/repo.git" into a slash separated entity path
+// ("owner/repo/some/dir"). It returns an empty string if the reference
+// does not contain at least an owner and a repository.
+func NormalizeRepository(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.Index(ref, "://"); i >= 0 {
+		ref = ref[i+3:]
+	}
+	ref = strings.TrimPrefix(ref, "www.")
+	ref = strings.TrimPrefix(ref, "github.com/")
+
+	var parts []string
+	for _, p := range strings.Split(ref, "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) < 2 {
+		return ""
+	}
+	parts[1] = strings.TrimSuffix(parts[1], ".git")
+	if parts[1] == "" {
+		return ""
+	}
+
+	// drop the "tree/<ref>" or "blob/<ref>" segments of deep links
+	if len(parts) > 3 && (parts[2] == "tree" || parts[2] == "blob") {
+		parts = append(parts[:2], parts[4:]...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// RepositoryURL returns the GitHub URL of an entity returned by the model.
+// Sub-path entities (directories of monorepos) link to the directory.
+func RepositoryURL(name string) string {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) == 3 {
+		return "https://github.com/" + parts[0] + "/" + parts[1] + "/tree/HEAD/" + parts[2]
+	}
+	return "https://github.com/" + name
+}
@@ -0,0 +1,90 @@
+package recommender
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRepository(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"tensorflow/tensorflow", "tensorflow/tensorflow"},
+		{" https://github.com/BVLC/caffe ", "BVLC/caffe"},
+		{"github.com/golang/go.git", "golang/go"},
+		{"https://www.github.com/google/jax?tab=readme#top", "google/jax"},
+		{"https://github.com/tensorflow/tensorflow/tree/master/tensorflow/compiler", "tensorflow/tensorflow/tensorflow/compiler"},
+		{"https://github.com/tensorflow/models/blob/v1.0/README.md", "tensorflow/models/README.md"},
+		{"tensorflow", ""},
+		{"github.com/golang/.git", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRepository(tt.ref); got != tt.want {
+			t.Errorf("NormalizeRepository(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestModelRepository(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"BVLC/caffe", "BVLC/caffe"},
+		{"bvlc/CAFFE", "BVLC/caffe"},
+		{"https://github.com/tensorflow/tensorflow/tree/master/tensorflow/compiler", "tensorflow/tensorflow"},
+		{"unknown/repository", ""},
+	}
+	for _, tt := range tests {
+		if got := model.Repository(tt.ref); got != tt.want {
+			t.Errorf("Repository(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestRepositoryURL(t *testing.T) {
+	if got := RepositoryURL("BVLC/caffe"); got != "https://github.com/BVLC/caffe" {
+		t.Errorf("Wrong URL: %v", got)
+	}
+	if got := RepositoryURL("google/research/bert"); got != "https://github.com/google/research/tree/HEAD/bert" {
+		t.Errorf("Wrong URL: %v", got)
+	}
+}
+
+func FuzzNormalizeRepository(f *testing.F) {
+	for _, ref := range []string{
+		"tensorflow/tensorflow",
+		" https://github.com/BVLC/caffe ",
+		"github.com/golang/go.git",
+		"https://www.github.com/google/jax?tab=readme#top",
+		"https://github.com/tensorflow/tensorflow/tree/master/tensorflow/compiler",
+		"//a//b/tree",
+		"",
+	} {
+		f.Add(ref)
+	}
+	f.Fuzz(func(t *testing.T, ref string) {
+		got := NormalizeRepository(ref)
+		if got == "" {
+			return
+		}
+		parts := strings.Split(got, "/")
+		if len(parts) < 2 {
+			t.Errorf("NormalizeRepository(%q) = %q, which has no repository", ref, got)
+		}
+		for _, part := range parts {
+			if part == "" {
+				t.Errorf("NormalizeRepository(%q) = %q, which has an empty segment", ref, got)
+			}
+		}
+		if strings.ContainsAny(got, "?#") {
+			t.Errorf("NormalizeRepository(%q) = %q, which has a query or fragment", ref, got)
+		}
+	})
+}
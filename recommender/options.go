@@ -0,0 +1,160 @@
+package recommender
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type (
+	// RecommendOption customizes the candidates considered by Recommend
+	RecommendOption func(*recommendOptions) error
+
+	recommendOptions struct {
+		excludePatterns     []string
+		excludeRepositories []string
+		includeItems        bool
+		filters             []Filter
+		languages           []string
+		topics              []string
+		// the lambda of MMR re-ranking, 1 without it
+		lambda float64
+		exact  bool
+	}
+)
+
+// ExcludeRepositories leaves out the given repositories, e.g. all the
+// stars of a user when only some of them are used as items.
+func ExcludeRepositories(repos ...string) RecommendOption {
+	return func(o *recommendOptions) error {
+		o.excludeRepositories = append(o.excludeRepositories, repos...)
+		return nil
+	}
+}
+
+// IncludeItems allows the items the recommendations are computed for to
+// be recommended too. By default they are left out.
+func IncludeItems() RecommendOption {
+	return func(o *recommendOptions) error {
+		o.includeItems = true
+		return nil
+	}
+}
+
+// Languages restricts the recommendations to repositories written in any
+// of the given languages, compared case-insensitively, e.g. "go". It needs
+// the languages.csv file of the model.
+func Languages(languages ...string) RecommendOption {
+	return func(o *recommendOptions) error {
+		o.languages = appendLabels(o.languages, languages)
+		return nil
+	}
+}
+
+// Topics restricts the recommendations to repositories with any of the
+// given GitHub topics, compared case-insensitively, e.g. "cli". It needs
+// the topics.csv file of the model.
+func Topics(topics ...string) RecommendOption {
+	return func(o *recommendOptions) error {
+		o.topics = appendLabels(o.topics, topics)
+		return nil
+	}
+}
+
+func appendLabels(labels []string, values []string) []string {
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			labels = append(labels, value)
+		}
+	}
+	return labels
+}
+
+// ExcludePatterns leaves out repositories matching any of the given glob
+// patterns (see path.Match), compared case-insensitively to the owner/name
+// of the repository. A pattern without a slash excludes a whole owner, so
+// "apache" is the same as "apache/*".
+func ExcludePatterns(patterns ...string) RecommendOption {
+	return func(o *recommendOptions) error {
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if pattern == "" {
+				continue
+			}
+			if !strings.Contains(pattern, "/") {
+				pattern += "/*"
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("Invalid exclude pattern %q: %v", pattern, err)
+			}
+			o.excludePatterns = append(o.excludePatterns, pattern)
+		}
+		return nil
+	}
+}
+
+// ExactSearch scores every repository instead of searching the index of
+// large models, e.g. to compare the results of both.
+func ExactSearch() RecommendOption {
+	return func(o *recommendOptions) error {
+		o.exact = true
+		return nil
+	}
+}
+
+// MMR re-ranks the recommendations with maximal marginal relevance, to
+// trade relevance for diversity: each pick maximizes
+// lambda * relevance - (1 - lambda) * similarity to the picks so far.
+// lambda is from 0 to 1, where 1 ranks by relevance alone.
+func MMR(lambda float64) RecommendOption {
+	return func(o *recommendOptions) error {
+		if lambda < 0 || lambda > 1 {
+			return fmt.Errorf("Invalid MMR lambda %v, expected a number from 0 to 1", lambda)
+		}
+		o.lambda = lambda
+		return nil
+	}
+}
+
+func newRecommendOptions(opts []RecommendOption) (*recommendOptions, error) {
+	o := &recommendOptions{lambda: 1}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// allowed tells whether a repository passes all the filters
+func (o *recommendOptions) allowed(repo string) bool {
+	if _, ok := o.excludedBy(repo); ok {
+		return false
+	}
+	return o.filtered(repo)
+}
+
+// excludedBy returns the first exclude pattern matching a repository
+func (o *recommendOptions) excludedBy(repo string) (string, bool) {
+	name := strings.ToLower(repo)
+	if parts := strings.SplitN(name, "/", 3); len(parts) == 3 {
+		// sub-path entities are matched by their repository
+		name = parts[0] + "/" + parts[1]
+	}
+	for _, pattern := range o.excludePatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// filtered tells whether a repository passes the plugin filters
+func (o *recommendOptions) filtered(repo string) bool {
+	for _, f := range o.filters {
+		if !f.Allow(repo) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package recommender
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExcludePatterns(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	recs, err := model.Recommend(context.Background(), items, 10, ExcludePatterns("TensorFlow", "*/*learn*"))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of recommendations: %v", recs)
+	}
+	for _, rec := range recs {
+		repo := strings.ToLower(rec.Repository)
+		if strings.HasPrefix(repo, "tensorflow/") || strings.Contains(strings.SplitN(repo, "/", 2)[1], "learn") {
+			t.Errorf("Excluded repository was recommended: %s", rec.Repository)
+		}
+	}
+}
+
+func TestInvalidExcludePattern(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	if _, err := model.Recommend(context.Background(), []string{"BVLC/caffe"}, 10, ExcludePatterns("apache/[")); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+func TestRecommendExcludesItems(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	starred := append([]string{"tensorflow/models", "fchollet/keras"}, items...)
+	recs, err := model.Recommend(context.Background(), items, 10, ExcludeRepositories(starred...))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of recommendations: %v", recs)
+	}
+	for _, rec := range recs {
+		for _, repo := range starred {
+			if rec.Repository == repo {
+				t.Errorf("Starred repository was recommended: %s", repo)
+			}
+		}
+	}
+
+	recs, err = model.Recommend(context.Background(), items, len(model.repositories), IncludeItems())
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	// the same repository is only recommended once
+	if len(recs) != len(model.repositories)-len(model.aliases) {
+		t.Errorf("Items were not included: %d recommendations", len(recs))
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	model := readModelWith(t, map[string]string{
+		"languages.csv": "repository,language\nBVLC/caffe,C++\ngolang/go,Go\n",
+	})
+	tests := []struct {
+		repo string
+		opts []RecommendOption
+		want string
+	}{
+		{"golang/go", nil, ""},
+		{"golang/go", []RecommendOption{ExcludePatterns("tensorflow", "Golang")}, `it matches the exclude pattern "golang/*"`},
+		{"golang/go", []RecommendOption{Languages("C++")}, "it is in none of the languages c++"},
+		{"BVLC/caffe", []RecommendOption{Languages("C++")}, ""},
+		{"golang/go", []RecommendOption{Filters(FilterFunc(func(repo string) bool { return repo != "golang/go" }))}, "it is hidden by a filter"},
+	}
+	for _, tt := range tests {
+		if got, err := model.Excluded(tt.repo, tt.opts...); err != nil || got != tt.want {
+			t.Errorf("Excluded(%s) = %q, %v, want %q", tt.repo, got, err, tt.want)
+		}
+	}
+	if _, err := model.Excluded("unknown/repository"); err == nil {
+		t.Errorf("Expected an error for an unknown repository")
+	}
+}
+
+// readModelWith reads the model in ../data/ with extra files added to a copy
+// of it
+func readModelWith(t *testing.T, files map[string]string) *Model {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatalf("Unable to create model directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"item_factors.npy", "items.csv"} {
+		data, err := ioutil.ReadFile("../data/" + name)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to copy %s: %v", name, err)
+		}
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+	model, err := ReadModel(dir + "/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	return model
+}
+
+func TestLanguages(t *testing.T) {
+	model := readModelWith(t, map[string]string{
+		"languages.csv": "repository,language\nBVLC/caffe,C++\ntensorflow/tensorflow,C++\nAlamofire/Alamofire,Swift\ngolang/go,Go\nunknown/repository,Go\n",
+	})
+	recs, err := model.Recommend(context.Background(), []string{"BVLC/caffe"}, 10, Languages("c++", " GO "))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 2 || recs[0].Repository == "Alamofire/Alamofire" || recs[1].Repository == "Alamofire/Alamofire" {
+		t.Errorf("Wrong recommendations: %v", recs)
+	}
+
+	noLanguages, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	if _, err := noLanguages.Recommend(context.Background(), []string{"BVLC/caffe"}, 10, Languages("go")); err == nil {
+		t.Errorf("Expected error without language data")
+	}
+}
+
+func TestTopics(t *testing.T) {
+	model := readModelWith(t, map[string]string{
+		"topics.csv": "repository,topic\nBVLC/caffe,deep-learning\ntensorflow/tensorflow,machine-learning\ntensorflow/tensorflow,deep-learning\nAlamofire/Alamofire,swift\n",
+	})
+	recs, err := model.Recommend(context.Background(), []string{"BVLC/caffe"}, 10, Topics("Machine-Learning"))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(recs) != 1 || recs[0].Repository != "tensorflow/tensorflow" {
+		t.Errorf("Wrong recommendations: %v", recs)
+	}
+	if _, err := model.Recommend(context.Background(), []string{"BVLC/caffe"}, 10, Languages("go")); err == nil {
+		t.Errorf("Expected error without language data")
+	}
+}
+
+func TestMMR(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	relevant, err := model.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	same, err := model.Recommend(context.Background(), items, 10, MMR(1))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if !reflect.DeepEqual(relevant, same) {
+		t.Errorf("MMR(1) changed the recommendations: %v", same)
+	}
+
+	diverse, err := model.Recommend(context.Background(), items, 10, MMR(0.3))
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+	if len(diverse) != 10 || diverse[0].Repository != relevant[0].Repository {
+		t.Fatalf("Wrong diverse recommendations: %v", diverse)
+	}
+	// the average pairwise similarity of the recommendations drops
+	similarity := func(recs []RepositoryScore) float64 {
+		sum := 0.0
+		for i := range recs {
+			for j := i + 1; j < len(recs); j++ {
+				a, _ := model.RepositoryID(recs[i].Repository)
+				b, _ := model.RepositoryID(recs[j].Repository)
+				sum += model.cosine(a, b)
+			}
+		}
+		return sum
+	}
+	if similarity(diverse) >= similarity(relevant) {
+		t.Errorf("MMR did not diversify: %v", diverse)
+	}
+
+	for _, lambda := range []float64{-0.1, 1.1} {
+		if _, err := model.Recommend(context.Background(), items, 10, MMR(lambda)); err == nil {
+			t.Errorf("Expected error for lambda %v", lambda)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package recommender
+
+import (
+	"math"
+
+	"github.com/jbochi/github-recs/lru"
+)
+
+// quantizedRescoreFactor is how many times more candidates than needed
+// are selected by their quantized scores, to be scored again exactly, so
+// quantization errors rarely change the top k.
+const quantizedRescoreFactor = 2
+
+// quantizedFactors are item factors stored as int8, with a scale per
+// vector: factor k of item i is about codes[i*nFactors+k] * scales[i]. They
+// take an eighth of the memory of float64 factors.
+type quantizedFactors struct {
+	nFactors int
+	codes    []int8
+	scales   []float64
+}
+
+// Quantized returns a copy of the model with int8 item factors, which
+// need 8 times less memory. Recommendations are selected with the
+// quantized factors and scored with the de-quantized ones, so they match
+// those of the original model but for near ties. The copy shares nothing
+// mutable with the model, which is left as is.
+func (m *Model) Quantized() *Model {
+	if m.quantized != nil {
+		return m
+	}
+	q := &quantizedFactors{nFactors: m.nFactors, codes: make([]int8, len(m.vectors)*m.nFactors), scales: make([]float64, len(m.vectors))}
+	for id, vector := range m.vectors {
+		q.scales[id] = quantizeVector(vector, q.codes[id*m.nFactors:(id+1)*m.nFactors])
+	}
+	quantized := *m
+	quantized.quantized = q
+	quantized.vectors = nil
+	// a mapped model is unmapped once it is no longer used
+	quantized.mapped = nil
+	// its queries differ slightly
+	quantized.queries = lru.New(queryCacheBytes)
+	return &quantized
+}
+
+// quantizeVector writes the int8 codes of a vector, and returns their scale
+func quantizeVector(vector []float64, codes []int8) float64 {
+	var max float64
+	for _, x := range vector {
+		max = math.Max(max, math.Abs(x))
+	}
+	if max == 0 {
+		for k := range codes {
+			codes[k] = 0
+		}
+		return 0
+	}
+	scale := max / math.MaxInt8
+	for k, x := range vector {
+		codes[k] = int8(math.Round(x / scale))
+	}
+	return scale
+}
+
+func (q *quantizedFactors) row(id int) []int8 {
+	return q.codes[id*q.nFactors : (id+1)*q.nFactors]
+}
+
+// vector returns the de-quantized factors of an item
+func (q *quantizedFactors) vector(id int) []float64 {
+	v := make([]float64, q.nFactors)
+	for k, c := range q.row(id) {
+		v[k] = float64(c) * q.scales[id]
+	}
+	return v
+}
+
+// dot returns the inner product of the factors of an item with a vector
+// quantized to codes and scale, in integer arithmetic
+func (q *quantizedFactors) dot(id int, codes []int8, scale float64) float64 {
+	var sum int64
+	for k, c := range q.row(id) {
+		sum += int64(c) * int64(codes[k])
+	}
+	return float64(sum) * q.scales[id] * scale
+}
+
+// vector returns the factors of an item, de-quantized if needed
+func (m *Model) vector(id int) []float64 {
+	if m.quantized != nil {
+		return m.quantized.vector(id)
+	}
+	return m.vectors[id]
+}
@@ -0,0 +1,81 @@
+package recommender
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	quantized := model.Quantized()
+	if quantized.vectors != nil || model.vectors == nil {
+		t.Errorf("Float factors were kept, or the model was changed")
+	}
+	if 4*quantized.MemoryFootprint() > 3*model.MemoryFootprint() {
+		t.Errorf("Quantized model is not smaller: %d, was %d", quantized.MemoryFootprint(), model.MemoryFootprint())
+	}
+
+	seeds := [][]string{
+		{"tensorflow/tensorflow", "BVLC/caffe"},
+		{"facebook/react", "vuejs/vue", "twbs/bootstrap", "webpack/webpack", "nodejs/node"},
+		{"golang/go"},
+		{"Alamofire/Alamofire", "AFNetworking/AFNetworking"},
+	}
+	found, total := 0, 0
+	for _, items := range seeds {
+		want, _ := model.Recommend(context.Background(), items, 10)
+		got, err := quantized.Recommend(context.Background(), items, 10)
+		if err != nil {
+			t.Fatalf("Failed to recommend: %v", err)
+		}
+		scores := map[string]float64{}
+		for _, rec := range want {
+			scores[rec.Repository] = rec.Score
+		}
+		for _, rec := range got {
+			total++
+			score, ok := scores[rec.Repository]
+			if !ok {
+				continue
+			}
+			found++
+			// scores come from the de-quantized factors
+			if math.Abs(rec.Score-score) > 0.02*math.Abs(score) {
+				t.Errorf("Score of %s is %v, want about %v", rec.Repository, rec.Score, score)
+			}
+		}
+		if got[0].Repository != want[0].Repository {
+			t.Errorf("Top recommendation for %v is %s, want %s", items, got[0].Repository, want[0].Repository)
+		}
+	}
+	t.Logf("overlap@10: %d/%d", found, total)
+	if overlap := float64(found) / float64(total); overlap < 0.9 {
+		t.Errorf("Quantized recommendations differ too much: %v", overlap)
+	}
+
+	similar, err := quantized.Similar("BVLC/caffe", 5)
+	if err != nil || len(similar) != 5 {
+		t.Errorf("Failed to find similar repositories: %v %v", similar, err)
+	}
+}
+
+func TestQuantizeVector(t *testing.T) {
+	vector := []float64{0.5, -1, 0.25, 0}
+	codes := make([]int8, len(vector))
+	scale := quantizeVector(vector, codes)
+	if codes[1] != -127 || codes[3] != 0 {
+		t.Errorf("Wrong codes: %v", codes)
+	}
+	for k, x := range vector {
+		if math.Abs(float64(codes[k])*scale-x) > scale/2 {
+			t.Errorf("Factor %d is %v, want %v", k, float64(codes[k])*scale, x)
+		}
+	}
+	if quantizeVector([]float64{0, 0}, codes[:2]) != 0 {
+		t.Errorf("Zero vector has a scale")
+	}
+}
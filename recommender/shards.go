@@ -0,0 +1,164 @@
+package recommender
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// maxNpyHeader bounds the header of a .npy file, whose length is at most
+// a uint16 in version 1 files, which are the ones numpy writes for small
+// headers
+const maxNpyHeader = 12 + math.MaxUint16
+
+// factorReader reads the item factors of a model file a row at a time,
+// so that a model can be built from the rows read so far
+type factorReader struct {
+	f          *os.File
+	r          *bufio.Reader
+	rows, cols int
+	// how many rows were read
+	read int
+	// the bytes of a row in the file, and how to decode them
+	rowBytes  int
+	decodeRow func(b []byte, row []float64)
+}
+
+// ReadModelInShards is like ReadModelWithBudget, but reads the item
+// factors in the given number of shards, in the order of the repositories
+// in the model files. After each shard but the last, it calls loaded with
+// a model of the repositories read so far, how many they are and how
+// many there are in all, e.g. to serve the first repositories while the
+// others load: a model whose files list repositories by popularity then
+// recommends the ones recommended most from the start.
+func ReadModelInShards(path string, budget int64, shards int, loaded func(partial *Model, read, total int)) (*Model, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("Invalid number of shards %d, expected at least 1", shards)
+	}
+	var fr *factorReader
+	var repositories []string
+	var err error
+	if _, statErr := os.Stat(path + binaryModelFile); statErr == nil {
+		fr, repositories, err = openBinaryFactors(path + binaryModelFile)
+	} else {
+		fr, err = openNpyFactors(path + "item_factors.npy")
+		if err == nil {
+			repositories, err = readItems(path+"items.csv", fr.rows)
+		}
+	}
+	if fr != nil {
+		defer fr.f.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMemoryBudget(fr.rows, fr.cols, false, budget); err != nil {
+		return nil, err
+	}
+
+	// the model is built as the shards are read, rather than again from
+	// the start for each partial model
+	b := newModelBuilder(fr.cols, repositories)
+	labels, err := readModelLabels(path, b.repositoryIDs, len(repositories))
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float64, 0, fr.rows*fr.cols)
+	for shard := 1; shard <= shards; shard++ {
+		end := fr.rows * shard / shards
+		data, err = fr.readRows(data, end)
+		if err != nil {
+			return nil, err
+		}
+		b.add(data)
+		if shard < shards && end > 0 {
+			partial := b.model()
+			partial.setLabels(labels)
+			loaded(partial, end, fr.rows)
+		}
+	}
+	m := b.model()
+	m.setLabels(labels)
+	return m, nil
+}
+
+// readRows appends the next rows of the file to data, up to row end
+func (fr *factorReader) readRows(data []float64, end int) ([]float64, error) {
+	buf := make([]byte, fr.rowBytes)
+	for ; fr.read < end; fr.read++ {
+		if _, err := io.ReadFull(fr.r, buf); err != nil {
+			return nil, fmt.Errorf("Unable to read row %d of the item factors: %v", fr.read, err)
+		}
+		row := data[len(data) : len(data)+fr.cols]
+		fr.decodeRow(buf, row)
+		data = data[:len(data)+fr.cols]
+	}
+	return data, nil
+}
+
+// openNpyFactors opens item_factors.npy, which must be a 2-dimensional,
+// C-ordered '<f8' array, the format the factors are saved in
+func openNpyFactors(filename string) (*factorReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read data: %v", err)
+	}
+	r := bufio.NewReaderSize(f, maxNpyHeader)
+	b, err := r.Peek(maxNpyHeader)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, fmt.Errorf("Unable to read data: %v", err)
+	}
+	offset, rows, cols, err := parseNpyHeader(b)
+	if err == nil {
+		// checked before the factors are allocated
+		var info os.FileInfo
+		info, err = f.Stat()
+		if err == nil && cols == 0 {
+			err = fmt.Errorf("no factors")
+		} else if err == nil && int64(rows) > (info.Size()-int64(offset))/8/int64(cols) {
+			err = fmt.Errorf("data is truncated")
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Unable to read data: %v", err)
+	}
+	r.Discard(offset)
+	return &factorReader{f: f, r: r, rows: rows, cols: cols, rowBytes: 8 * cols, decodeRow: func(b []byte, row []float64) {
+		for k := range row {
+			row[k] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*k:]))
+		}
+	}}, nil
+}
+
+// openBinaryFactors opens a binary model file, reading its names from the
+// string table at its end first
+func openBinaryFactors(filename string) (*factorReader, []string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to open %s: %v", binaryModelFile, err)
+	}
+	r := bufio.NewReader(f)
+	rows, cols, err := decodeBinaryHeader(r)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("Unable to read %s: %v", binaryModelFile, err)
+	}
+	headerBytes := int64(binary.Size(binaryModelHeader{}))
+	table := io.NewSectionReader(f, headerBytes+int64(rows)*int64(cols)*4, math.MaxInt64)
+	repositories, err := decodeBinaryNames(bufio.NewReader(table), rows)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("Unable to read %s: %v", binaryModelFile, err)
+	}
+	return &factorReader{f: f, r: r, rows: rows, cols: cols, rowBytes: 4 * cols, decodeRow: func(b []byte, row []float64) {
+		for k := range row {
+			row[k] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[4*k:])))
+		}
+	}}, repositories, nil
+}
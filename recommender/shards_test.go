@@ -0,0 +1,82 @@
+package recommender
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadModelInShards(t *testing.T) {
+	model, err := ReadModel("../data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	items := []string{"tensorflow/tensorflow", "BVLC/caffe"}
+	want, err := model.Recommend(context.Background(), items, 10)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %s", err)
+	}
+
+	// the same model, from item_factors.npy and from a binary model file
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatalf("Unable to create model directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"item_factors.npy", "items.csv"} {
+		data, err := ioutil.ReadFile("../data/" + name)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to copy %s: %v", name, err)
+		}
+	}
+	for _, format := range []string{"npy", "binary"} {
+		if format == "binary" {
+			if err := ConvertModel(dir + "/"); err != nil {
+				t.Fatalf("Unable to convert model: %v", err)
+			}
+		}
+		var progress []int
+		sharded, err := ReadModelInShards(dir+"/", 0, 4, func(partial *Model, read, total int) {
+			if partial.NumRepositories() != read || total != model.NumRepositories() {
+				t.Errorf("Wrong %s partial model: %d repositories, read %d of %d", format, partial.NumRepositories(), read, total)
+			}
+			if _, err := partial.Recommend(context.Background(), items, 10); err != nil {
+				t.Errorf("Partial %s model failed to recommend: %v", format, err)
+			}
+			// built from the rows read so far, and searched exhaustively
+			if partial.index != nil || !reflect.DeepEqual(partial.yty, gramian(partial.vectors, partial.nFactors)) {
+				t.Errorf("Wrong %s partial model after %d repositories", format, read)
+			}
+			if _, ok := partial.RepositoryID(model.repositories[read]); ok {
+				t.Errorf("Partial %s model knows %s, which was not read yet", format, model.repositories[read])
+			}
+			progress = append(progress, read)
+		})
+		if err != nil {
+			t.Fatalf("Unable to read %s model in shards: %v", format, err)
+		}
+		if !reflect.DeepEqual(progress, []int{250, 500, 750}) {
+			t.Errorf("Wrong %s progress: %v", format, progress)
+		}
+		got, err := sharded.Recommend(context.Background(), items, 10)
+		if err != nil {
+			t.Fatalf("Failed to recommend: %s", err)
+		}
+		for i := range want {
+			// binary models store float32 factors
+			if got[i].Repository != want[i].Repository {
+				t.Errorf("Wrong %s recommendation %d: %v, want %v", format, i, got[i], want[i])
+			}
+		}
+	}
+
+	if _, err := ReadModelInShards("../data/", 0, 0, nil); err == nil {
+		t.Errorf("Expected an error for 0 shards")
+	}
+}
@@ -0,0 +1,392 @@
+// Package recsclient is a client of the API of the server. It retries the
+// requests that fail for a while, as while the server loads its model or
+// is rate limited, with exponential backoff, and follows the pages of the
+// stars and the batches of the batch API for its callers:
+//
+//	client := recsclient.New("https://example.com")
+//	recs, err := client.Recommend(ctx, []string{"golang/go"}, recsclient.N(5))
+package recsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBatchRequests is how many requests the batch API serves at once;
+// RecommendBatch splits larger batches
+const MaxBatchRequests = 100
+
+type (
+	// Client calls the API of a server. Its fields are set by New and may
+	// be changed before it is used; it is then safe for concurrent use.
+	Client struct {
+		// BaseURL is the URL of the server, without a trailing slash
+		BaseURL string
+		// HTTPClient sends the requests
+		HTTPClient *http.Client
+		// Token is the GitHub token of a user, sent as the cookie the
+		// server signs users in with, for their recommendations and stars
+		Token string
+		// MaxRetries is how many times a failed request is retried
+		MaxRetries int
+		// MinBackoff is the wait before the first retry, doubled before
+		// each of the next ones up to MaxBackoff. A Retry-After of the
+		// server longer than MaxBackoff is not waited for, and its error
+		// returned instead.
+		MinBackoff time.Duration
+		MaxBackoff time.Duration
+	}
+
+	// Option sets a parameter of the recommendations
+	Option func(url.Values)
+
+	// Recommendation is a recommended repository
+	Recommendation struct {
+		ID         int64   `json:"id"`
+		Repository string  `json:"repository"`
+		Score      float64 `json:"score"`
+		// the items that contributed the most to the score
+		Because []string `json:"because,omitempty"`
+		// the 1-based position in the list
+		Rank int `json:"rank"`
+	}
+
+	// Recommendations are the recommendations for seed repositories, or
+	// the stars of a user
+	Recommendations struct {
+		User string `json:"user,omitempty"`
+		// the repositories the recommendations are for
+		Items           []string         `json:"items"`
+		Recommendations []Recommendation `json:"recommendations"`
+		// the seed repositories the model does not know, left out
+		Unknown      []string `json:"unknown,omitempty"`
+		ModelVersion string   `json:"model_version"`
+	}
+
+	// BatchRequest asks for the recommendations of a GitHub user, from
+	// their public stars, or of seed repositories
+	BatchRequest struct {
+		// echoed back in the result
+		ID    string   `json:"id,omitempty"`
+		User  string   `json:"user,omitempty"`
+		Repos []string `json:"repos,omitempty"`
+	}
+
+	// BatchResult is the result of a BatchRequest
+	BatchResult struct {
+		ID              string           `json:"id,omitempty"`
+		User            string           `json:"user,omitempty"`
+		Items           []string         `json:"items"`
+		Recommendations []Recommendation `json:"recommendations"`
+		Unknown         []string         `json:"unknown,omitempty"`
+		// why there are no recommendations for this request
+		Error string `json:"error,omitempty"`
+		// the version of the model of the batch of the result
+		ModelVersion string `json:"-"`
+	}
+
+	// Star is a star of the signed in user and how the model sees it
+	Star struct {
+		Repository string    `json:"repository"`
+		StarredAt  time.Time `json:"starred_at"`
+		InModel    bool      `json:"in_model"`
+		Entity     string    `json:"entity,omitempty"`
+		Weight     float64   `json:"weight"`
+	}
+
+	// StarsPage is a page of the stars of the signed in user
+	StarsPage struct {
+		User    string `json:"user"`
+		Total   int    `json:"total"`
+		InModel int    `json:"in_model"`
+		Page    int    `json:"page"`
+		PerPage int    `json:"per_page"`
+		Stars   []Star `json:"stars"`
+		// the URL of the next page, from the Link header, "" on the last
+		next string
+	}
+
+	// Error is an error response of the API
+	Error struct {
+		StatusCode int
+		Message    string
+		// when to try again, from the Retry-After header, if the server
+		// sent one
+		RetryAfter time.Duration
+	}
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// temporary tells whether a request that failed with the error may
+// succeed if retried
+func (e *Error) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// New returns a client of the server at baseURL, which retries failed
+// requests 3 times, waiting from half a second to half a minute
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// N sets the number of recommendations, up to 100
+func N(n int) Option {
+	return func(v url.Values) { v.Set("n", strconv.Itoa(n)) }
+}
+
+// Languages restricts the recommendations to repositories in any of the
+// languages
+func Languages(languages ...string) Option {
+	return func(v url.Values) { v.Set("lang", strings.Join(languages, ",")) }
+}
+
+// Topics restricts the recommendations to repositories with any of the
+// topics
+func Topics(topics ...string) Option {
+	return func(v url.Values) { v.Set("topic", strings.Join(topics, ",")) }
+}
+
+// Lambda diversifies the recommendations with maximal marginal relevance,
+// 1 to leave them as they are
+func Lambda(lambda float64) Option {
+	return func(v url.Values) { v.Set("lambda", strconv.FormatFloat(lambda, 'f', -1, 64)) }
+}
+
+// Recommend returns the recommendations for seed repositories, or for the
+// stars of the user of the Token without any
+func (c *Client) Recommend(ctx context.Context, repos []string, opts ...Option) (*Recommendations, error) {
+	query := url.Values{}
+	if len(repos) > 0 {
+		query.Set("repos", strings.Join(repos, ","))
+	}
+	for _, opt := range opts {
+		opt(query)
+	}
+	var recs Recommendations
+	if _, err := c.do(ctx, "GET", c.BaseURL+"/api/v1/recommendations?"+query.Encode(), nil, &recs); err != nil {
+		return nil, err
+	}
+	return &recs, nil
+}
+
+// RecommendBatch returns the results of any number of requests, in their
+// order, sending them MaxBatchRequests at a time. A request that fails has
+// the Error of its result set, and does not fail the others.
+func (c *Client) RecommendBatch(ctx context.Context, requests []BatchRequest, opts ...Option) ([]BatchResult, error) {
+	query := url.Values{}
+	for _, opt := range opts {
+		opt(query)
+	}
+	results := make([]BatchResult, 0, len(requests))
+	for start := 0; start < len(requests); start += MaxBatchRequests {
+		end := start + MaxBatchRequests
+		if end > len(requests) {
+			end = len(requests)
+		}
+		body, err := json.Marshal(map[string]interface{}{"requests": requests[start:end]})
+		if err != nil {
+			return nil, err
+		}
+		var batch struct {
+			Results      []BatchResult `json:"results"`
+			ModelVersion string        `json:"model_version"`
+		}
+		if _, err := c.do(ctx, "POST", c.BaseURL+"/api/v1/recommendations:batch?"+query.Encode(), body, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch.Results) != end-start {
+			return nil, fmt.Errorf("Unexpected response with %d results to %d requests", len(batch.Results), end-start)
+		}
+		for _, result := range batch.Results {
+			result.ModelVersion = batch.ModelVersion
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Stars returns a page of the stars of the user of the Token, from 1, of
+// perPage stars, up to 100
+func (c *Client) Stars(ctx context.Context, page, perPage int) (*StarsPage, error) {
+	query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+	return c.starsPage(ctx, c.BaseURL+"/api/v1/stars?"+query.Encode())
+}
+
+// Next returns the page after p, or nil if p is the last
+func (c *Client) Next(ctx context.Context, p *StarsPage) (*StarsPage, error) {
+	if p.next == "" {
+		return nil, nil
+	}
+	return c.starsPage(ctx, p.next)
+}
+
+// AllStars returns all the stars of the user of the Token, following the
+// pages of the stars API
+func (c *Client) AllStars(ctx context.Context) ([]Star, error) {
+	var stars []Star
+	page, err := c.Stars(ctx, 1, 100)
+	for ; page != nil && err == nil; page, err = c.Next(ctx, page) {
+		stars = append(stars, page.Stars...)
+	}
+	return stars, err
+}
+
+func (c *Client) starsPage(ctx context.Context, u string) (*StarsPage, error) {
+	var page StarsPage
+	header, err := c.do(ctx, "GET", u, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+	if next := nextPageURL(header.Get("Link")); next != "" {
+		// the server links to its paths
+		base, err := url.Parse(c.BaseURL + "/")
+		if err != nil {
+			return nil, err
+		}
+		ref, err := url.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Link header: %v", err)
+		}
+		page.next = base.ResolveReference(ref).String()
+	}
+	return &page, nil
+}
+
+// do sends a request, retrying it while it fails for a while, and decodes
+// its JSON response into result
+func (c *Client) do(ctx context.Context, method, u string, body []byte, result interface{}) (http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		header, retry, err := c.send(ctx, method, u, body, result)
+		if err == nil || !retry || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return header, err
+		}
+		wait := c.backoff(attempt)
+		if apiErr, ok := err.(*Error); ok {
+			if apiErr.RetryAfter > c.MaxBackoff {
+				return header, err
+			}
+			if apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before a retry: MinBackoff doubled for each
+// attempt, up to MaxBackoff, less up to half of it at random so that
+// clients that failed together do not retry together
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.MinBackoff
+	for i := 0; i < attempt && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d - time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// send sends a request once, and tells whether it may succeed if retried
+// when it fails: the requests of the API do not change anything, so any
+// of them can be sent again
+func (c *Client) send(ctx context.Context, method, u string, body []byte, result interface{}) (header http.Header, retry bool, err error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.AddCookie(&http.Cookie{Name: "token", Value: c.Token})
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(b, &errResp) == nil && errResp.Error != "" {
+			apiErr.Message = errResp.Error
+		} else {
+			apiErr.Message = resp.Status
+		}
+		return resp.Header, apiErr.temporary(), apiErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, false, fmt.Errorf("Invalid API response: %v", err)
+	}
+	return resp.Header, false, nil
+}
+
+// retryAfter parses a Retry-After header, in seconds or an HTTP date
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, e.g.
+// </api/v1/stars?page=2>; rel="next"
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		fields := strings.Split(part, ";")
+		if len(fields) < 2 {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(fields[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
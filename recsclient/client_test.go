@@ -0,0 +1,172 @@
+package recsclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(url string) *Client {
+	c := New(url)
+	c.MinBackoff, c.MaxBackoff = time.Millisecond, 10*time.Millisecond
+	return c
+}
+
+func TestRecommendRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, `{"error": "The model is warming up"}`)
+			return
+		}
+		if r.URL.Path != "/api/v1/recommendations" || r.FormValue("repos") != "golang/go,rust-lang/rust" || r.FormValue("n") != "2" || r.FormValue("lang") != "go" {
+			t.Errorf("Wrong request: %s", r.URL)
+		}
+		fmt.Fprintln(w, `{"items": ["golang/go"], "unknown": ["rust-lang/rust"], "recommendations": [{"repository": "a/b", "rank": 1}], "model_version": "v1"}`)
+	}))
+	defer server.Close()
+
+	recs, err := newTestClient(server.URL+"/").Recommend(context.Background(), []string{"golang/go", "rust-lang/rust"}, N(2), Languages("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || recs.ModelVersion != "v1" || len(recs.Recommendations) != 1 || recs.Recommendations[0].Repository != "a/b" || recs.Unknown[0] != "rust-lang/rust" {
+		t.Errorf("Wrong recommendations after %d calls: %+v", calls, recs)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var calls int32
+	status, retryAfter := http.StatusBadRequest, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		fmt.Fprintln(w, `{"error": "Nope"}`)
+	}))
+	defer server.Close()
+	c := newTestClient(server.URL)
+
+	// client errors are not retried
+	_, err := c.Recommend(context.Background(), []string{"not/there"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Nope" || calls != 1 {
+		t.Errorf("Wrong error after %d calls: %v", calls, err)
+	}
+
+	// server errors are, MaxRetries times
+	calls, status = 0, http.StatusBadGateway
+	if _, err := c.Recommend(context.Background(), []string{"golang/go"}); err == nil || calls != 4 {
+		t.Errorf("Wrong error after %d calls: %v", calls, err)
+	}
+
+	// but not when the server asks for a wait longer than MaxBackoff
+	calls, status, retryAfter = 0, http.StatusTooManyRequests, "300"
+	_, err = c.Recommend(context.Background(), []string{"golang/go"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.RetryAfter != 300*time.Second || calls != 1 {
+		t.Errorf("Wrong error after %d calls: %v", calls, err)
+	}
+
+	// and the context bounds the retries
+	calls, retryAfter = 0, ""
+	c.MinBackoff, c.MaxBackoff = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Recommend(ctx, []string{"golang/go"}); err != context.DeadlineExceeded || calls != 1 {
+		t.Errorf("Wrong error after %d calls: %v", calls, err)
+	}
+}
+
+func TestRecommendBatch(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch struct {
+			Requests []BatchRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, len(batch.Requests))
+		var results []BatchResult
+		for _, req := range batch.Requests {
+			results = append(results, BatchResult{ID: req.ID, User: req.User})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "model_version": "v2"})
+	}))
+	defer server.Close()
+
+	var requests []BatchRequest
+	for i := 0; i < 250; i++ {
+		requests = append(requests, BatchRequest{ID: strconv.Itoa(i), User: "octocat"})
+	}
+	results, err := newTestClient(server.URL).RecommendBatch(context.Background(), requests, N(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(batches) != "[100 100 50]" || len(results) != 250 || results[249].ID != "249" || results[0].ModelVersion != "v2" {
+		t.Errorf("Wrong results of batches %v: %d", batches, len(results))
+	}
+}
+
+func TestAllStars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("token"); err != nil || cookie.Value != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, `{"error": "Unauthorized: sign in with GitHub"}`)
+			return
+		}
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</api/v1/stars?page=%d&per_page=100>; rel="next"`, page+1))
+		}
+		json.NewEncoder(w).Encode(StarsPage{Page: page, Total: 3, Stars: []Star{{Repository: fmt.Sprintf("a/%d", page)}}})
+	}))
+	defer server.Close()
+	c := newTestClient(server.URL)
+
+	if _, err := c.AllStars(context.Background()); err == nil {
+		t.Errorf("Expected an error without a token")
+	}
+	c.Token = "secret"
+	stars, err := c.AllStars(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stars) != 3 || stars[2].Repository != "a/3" {
+		t.Errorf("Wrong stars: %v", stars)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New("http://localhost")
+	for attempt, max := range []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second} {
+		if d := c.backoff(attempt); d < max/2 || d > max {
+			t.Errorf("Wrong backoff of attempt %d: %v", attempt, d)
+		}
+	}
+	if d := c.backoff(100); d > c.MaxBackoff {
+		t.Errorf("Backoff over MaxBackoff: %v", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"soon":                          0,
+		"Wed, 01 Jan 2020 00:01:00 GMT": time.Minute,
+	} {
+		if d := retryAfter(value, now); d != expected {
+			t.Errorf("Wrong Retry-After of %q: %v", value, d)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+var (
+	// modelMu guards models, the loaded model of each version, which
+	// /admin/reload replaces. Handlers take the model of a request once,
+	// with currentModel or modelForRequest, and use it throughout the
+	// request.
+	modelMu sync.RWMutex
+	models  = map[string]*recommender.Model{}
+	// modelDir is where the models are loaded from at startup, and
+	// reloaded from by default
+	modelDir = "./data/"
+	// adminToken authorizes the /admin/ endpoints, set with ADMIN_TOKEN.
+	// They are disabled without it.
+	adminToken string
+)
+
+// currentModel returns the model of the default version
+func currentModel() *recommender.Model {
+	return versionModel(modelVersions[0].name)
+}
+
+func versionModel(version string) *recommender.Model {
+	modelMu.RLock()
+	defer modelMu.RUnlock()
+	return models[version]
+}
+
+// setModel replaces the model of the default version
+func setModel(m *recommender.Model) {
+	setVersionModel(modelVersions[0].name, m)
+}
+
+// setVersionModel replaces the model of a version. Requests in flight
+// keep using the previous one, which is left to the garbage collector.
+func setVersionModel(version string, m *recommender.Model) {
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	models[version] = m
+}
+
+// loadModel reads the model in path as configured: memory mapped,
+// quantized and within the memory budget.
+func loadModel(path string) (*recommender.Model, error) {
+	var m *recommender.Model
+	var err error
+	if modelMapped {
+		m, err = recommender.ReadMappedModel(path, modelMemoryBudget)
+	} else {
+		m, err = recommender.ReadModelWithBudget(path, modelMemoryBudget)
+	}
+	if err == nil && modelQuantized {
+		m = m.Quantized()
+	}
+	return m, err
+}
+
+// reloadModel loads the model in the path parameter, or the startup one,
+// and swaps it in once it is fully loaded. The version parameter picks
+// the version replaced, the default one if it is empty. Requests must be
+// POSTs with an "Authorization: Bearer <ADMIN_TOKEN>" header. It replies
+// with the status of the new model.
+func reloadModel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	if !checkAdmin(w, r) {
+		return
+	}
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		apiError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version := modelVersions[0]
+	if name := r.FormValue("version"); name != "" {
+		var ok bool
+		if version, ok = findModelVersion(name); !ok {
+			apiError(w, r, fmt.Sprintf("Unknown model version %q", name), http.StatusBadRequest)
+			return
+		}
+	}
+	path := r.FormValue("path")
+	if path == "" {
+		path = modelVersionPath(version.name)
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	m, err := loadModel(path)
+	if err != nil {
+		logErrorf("Failed to reload the model from %s: %v", path, err)
+		apiError(w, r, "Failed to load the model: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	setVersionModel(version.name, m)
+	logWarningf("Reloaded the model %s from %s: %d repositories", version.name, path, m.NumRepositories())
+	writeJSON(w, r, http.StatusOK, statusResponse{Model: newModelStatus(m, version), Maintenance: maintenanceMessage, Caches: cacheStats(m)})
+}
+
+// checkAdmin tells whether a request has the admin token, and replies
+// with an error if it does not
+func checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		apiError(w, r, "Admin endpoints are disabled", http.StatusNotFound)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		apiError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+func TestReloadModel(t *testing.T) {
+	defer func(saved string) { adminToken = saved }(adminToken)
+	defer setModel(currentModel())
+	before := currentModel()
+
+	reload := func(method, url, token string) int {
+		r := httptest.NewRequest(method, url, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		reloadModel(w, r)
+		return w.Code
+	}
+
+	adminToken = ""
+	if code := reload("POST", "/admin/reload", "secret"); code != http.StatusNotFound {
+		t.Errorf("Wrong status without an admin token: %d", code)
+	}
+	adminToken = "secret"
+	tests := []struct {
+		method, url, token string
+		want               int
+	}{
+		{"POST", "/admin/reload", "", http.StatusUnauthorized},
+		{"POST", "/admin/reload", "wrong", http.StatusUnauthorized},
+		{"GET", "/admin/reload", "secret", http.StatusMethodNotAllowed},
+		{"POST", "/admin/reload?path=/nonexistent", "secret", http.StatusUnprocessableEntity},
+		{"POST", "/admin/reload?version=unknown", "secret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := reload(tt.method, tt.url, tt.token); code != tt.want {
+			t.Errorf("%s %s with token %q: %d, want %d", tt.method, tt.url, tt.token, code, tt.want)
+		}
+	}
+	if currentModel() != before {
+		t.Fatalf("The model was replaced by failed reloads")
+	}
+
+	if code := reload("POST", "/admin/reload?path=./data", "secret"); code != http.StatusOK {
+		t.Fatalf("Failed to reload: %d", code)
+	}
+	if m := currentModel(); m == before || m == nil || m.NumRepositories() != before.NumRepositories() {
+		t.Errorf("The model was not replaced")
+	}
+}
+
+// TestConcurrentRecommendDuringReload recommends from hundreds of
+// goroutines while the model is reloaded, in all its variants. Run it
+// with -race.
+func TestConcurrentRecommendDuringReload(t *testing.T) {
+	defer setModel(currentModel())
+	const workers, requests = 200, 5
+
+	stop := make(chan bool)
+	reloaded := make(chan int)
+	go func() {
+		reloads := 0
+		defer func() { reloaded <- reloads }()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			var m *recommender.Model
+			var err error
+			switch reloads % 3 {
+			case 0:
+				m, err = recommender.ReadModel("./data/")
+			case 1:
+				m, err = recommender.ReadMappedModel("./data/", 0)
+			case 2:
+				m, err = recommender.ReadModel("./data/")
+				if err == nil {
+					m = m.Quantized()
+				}
+			}
+			if err != nil {
+				t.Errorf("Unable to reload: %v", err)
+				return
+			}
+			setModel(m)
+			// finalizers unmap the mapped models no longer used
+			runtime.GC()
+			reloads++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			items := demoStars[:1+i%len(demoStars)]
+			for j := 0; j < requests; j++ {
+				m := currentModel()
+				recs, err := m.Recommend(context.Background(), items, 10, recommender.MMR(0.5))
+				if err != nil || len(recs) != 10 {
+					t.Errorf("Failed to recommend: %v %v", recs, err)
+					return
+				}
+				if _, err := m.Similar(recs[0].Repository, 5); err != nil {
+					t.Errorf("Failed to find similar repositories: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	t.Logf("%d reloads", <-reloaded)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+type errorTemplateVars struct {
+	Status  int
+	Message string
+}
+
+var errorTemplate = parseTemplates("templates/base.html", "templates/error.html")
+
+// render executes the named template into a buffer and only writes it to
+// the response if it succeeds, so a failure mid-template shows a proper
+// error page instead of half of the layout. Render latency is logged per
+// template. Numbers and dates are formatted in the locale of the client,
+// and clients asking for JSON get the template variables instead.
+func render(w http.ResponseWriter, r *http.Request, name string, vars interface{}) {
+	renderFragment(w, r, name, "base.html", vars)
+}
+
+// renderFragment is like render, but executes only the given fragment of
+// the named template instead of the whole layout.
+func renderFragment(w http.ResponseWriter, r *http.Request, name string, fragment string, vars interface{}) {
+	if wantsJSON(r) {
+		writeJSON(w, r, http.StatusOK, vars)
+		return
+	}
+	start := time.Now()
+	var buf bytes.Buffer
+	err := localizedTemplate(name, requestLocale(r)).ExecuteTemplate(&buf, fragment, vars)
+	logDebugf("rendered template %s (%s) in %v", name, fragment, time.Since(start))
+	if err != nil {
+		logErrorf("Failed to render template %s: %v", name, err)
+		renderError(w, r, http.StatusInternalServerError, "Something went wrong while rendering this page.")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		logWarningf("Failed to write template %s: %v", name, err)
+	}
+}
+
+// renderError responds with the styled error page, falling back to a
+// plain text error if even that fails.
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	w.Header().Del("Surrogate-Key")
+	if wantsJSON(r) {
+		writeJSON(w, r, status, errorResponse{message})
+		return
+	}
+
+	var buf bytes.Buffer
+	vars := errorTemplateVars{Status: status, Message: message}
+	if err := errorTemplate.ExecuteTemplate(&buf, "base.html", vars); err != nil {
+		logErrorf("Failed to render error page: %v", err)
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
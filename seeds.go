@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+// knownRepositories splits seed repositories into those the model knows,
+// which the recommendations are for, and the others
+func knownRepositories(model *recommender.Model, repos []string) (known, unknown []string) {
+	for _, repo := range repos {
+		if model.Repository(repo) == "" {
+			unknown = append(unknown, repo)
+		} else {
+			known = append(known, repo)
+		}
+	}
+	return known, unknown
+}
+
+// unknownRepositoriesError is the error of a request none of whose seed
+// repositories the model knows
+func unknownRepositoriesError(unknown []string) error {
+	return fmt.Errorf("Unknown repos: %s. Only public repos with enough stars are known", strings.Join(unknown, ", "))
+}
+
+// seedsPage renders the recommendations for the seed repositories of the
+// repos parameter of the home page, as in
+// /?repos=tensorflow/tensorflow,BVLC/caffe, without signing in. The repos
+// the model does not know are listed on the page and left out.
+func seedsPage(w http.ResponseWriter, r *http.Request) {
+	model, version := modelForRequest(r)
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+
+	repos, err := formList(r, "repos", maxSeeds)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(repos) == 0 {
+		renderError(w, r, http.StatusBadRequest, "Sorry, I need at least one repo to recommend from, e.g. /?repos=golang/go")
+		return
+	}
+	seeds, unknown := knownRepositories(model, repos)
+	if len(seeds) == 0 {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Sorry, I don't know any of these repos: %s.", strings.Join(unknown, ", ")))
+		return
+	}
+	opts, err := formLabels(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	recs, err := model.Recommend(r.Context(), seeds, numRecommendations, append(opts, recommender.Filters(filters...))...)
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), recommendStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	setPublicCacheHeaders(w, "seeds")
+	render(w, r, "recs", recommendationsTemplateVars{
+		Stars:        seeds,
+		StarPreview:  seeds,
+		Recs:         recs,
+		Demo:         true,
+		Seeds:        true,
+		Unknown:      unknown,
+		ModelVersion: version,
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeedsPage(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		status int
+		want   string
+	}{
+		{"/?repos=tensorflow/tensorflow,BVLC/caffe", http.StatusOK, "GitHub Recs:"},
+		{"/?repos=nobody/nothing,nobody/else", http.StatusBadRequest, "nobody/nothing, nobody/else"},
+		{"/?repos=", http.StatusBadRequest, "at least one repo"},
+	} {
+		w := httptest.NewRecorder()
+		home(w, httptest.NewRequest("GET", test.url, nil))
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("Wrong response for %s: %d %s", test.url, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAPIRecommendationsUnknownSeeds(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=tensorflow/tensorflow,nobody/nothing", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status: %d %s", w.Code, w.Body.String())
+	}
+	var response apiRecommendationsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(response.Items, []string{"tensorflow/tensorflow"}) || !reflect.DeepEqual(response.Unknown, []string{"nobody/nothing"}) {
+		t.Errorf("Wrong items: %v, unknown: %v", response.Items, response.Unknown)
+	}
+
+	w = httptest.NewRecorder()
+	apiRecommendations(w, httptest.NewRequest("GET", "/api/v1/recommendations?repos=nobody/nothing", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Unknown repos: nobody/nothing") {
+		t.Errorf("Wrong response without known seeds: %d %s", w.Code, w.Body.String())
+	}
+}
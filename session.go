@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/jbochi/github-recs/lru"
+)
+
+const (
+	// sessionTTL is how long the login of a token is trusted without
+	// asking GitHub again. The stars of every request are still fetched
+	// with the token, so a revoked one stops working right away.
+	sessionTTL = 5 * time.Minute
+	// sessionCacheBytes bounds the sessions kept; the least recently used
+	// go first
+	sessionCacheBytes = 4 << 20
+	// cachedSessionSize approximates the overhead of a session in the
+	// cache, besides the login
+	cachedSessionSize = 64
+)
+
+type cachedSession struct {
+	login   string
+	expires time.Time
+}
+
+// sessions are the logins of the tokens seen recently, by the digest of
+// the token, so tokens are not kept in memory
+var sessions = lru.New(sessionCacheBytes)
+
+// tokenDigest identifies the user of a request, without keeping their
+// token, or returns "" if they are not signed in
+func tokenDigest(r *http.Request) string {
+	cookie, _ := r.Cookie("token")
+	if cookie == nil || cookie.Value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cookie.Value))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedLogin returns the login of the user of a request, if it was seen
+// within sessionTTL
+func cachedLogin(r *http.Request, now time.Time) (string, bool) {
+	digest := tokenDigest(r)
+	if digest == "" {
+		return "", false
+	}
+	value, ok := sessions.Get(digest)
+	if !ok || !now.Before(value.(cachedSession).expires) {
+		return "", false
+	}
+	return value.(cachedSession).login, true
+}
+
+func cacheLogin(r *http.Request, login string, now time.Time) {
+	if digest := tokenDigest(r); digest != "" {
+		sessions.Add(digest, cachedSession{login, now.Add(sessionTTL)}, int64(len(login))+cachedSessionSize)
+	}
+}
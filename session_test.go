@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachedLogin(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "token", Value: "session-test"})
+	now := time.Now()
+	if _, ok := cachedLogin(r, now); ok {
+		t.Fatalf("Unknown token has a login")
+	}
+	cacheLogin(r, "octocat", now)
+	if login, ok := cachedLogin(r, now.Add(time.Minute)); !ok || login != "octocat" {
+		t.Errorf("Cached login: %q %v", login, ok)
+	}
+	if _, ok := cachedLogin(r, now.Add(sessionTTL)); ok {
+		t.Errorf("Expired login was returned")
+	}
+
+	anonymous := httptest.NewRequest("GET", "/", nil)
+	cacheLogin(anonymous, "nobody", now)
+	if _, ok := cachedLogin(anonymous, now); ok {
+		t.Errorf("Request without a token has a login")
+	}
+}
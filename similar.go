@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const numSimilar = 10
+
+type similarTemplateVars struct {
+	Repository string            `json:"repository"`
+	Similar    []RepositoryScore `json:"similar"`
+}
+
+// similar lists the repositories most similar to the one in the path, as
+// in /similar/tensorflow/tensorflow. It is not personalized, so anyone
+// can link to it and shared caches can keep it.
+func similar(w http.ResponseWriter, r *http.Request) {
+	model, version := modelForRequest(r)
+	if model == nil {
+		httpError(w, r, "model was not initialized", http.StatusInternalServerError)
+		return
+	}
+	setModelVersionHeader(w, version)
+	ref := strings.TrimPrefix(r.URL.Path, "/similar/")
+	if ref == "" {
+		ref = r.FormValue("repo")
+	}
+	repo := model.Repository(ref)
+	if repo == "" {
+		renderError(w, r, http.StatusNotFound, fmt.Sprintf("Sorry, I don't know the repository %q.", ref))
+		return
+	}
+
+	recs, err := model.Similar(repo, numSimilar, recommender.Filters(filters...))
+	if err != nil {
+		httpError(w, r, fmt.Sprintf("Failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setPublicCacheHeaders(w, "similar", "similar/"+repo)
+	render(w, r, "similar", similarTemplateVars{repo, recs})
+}
+
+// similarPath returns the path of the similar repositories page
+func similarPath(repo string) string {
+	return "/similar/" + repo
+}
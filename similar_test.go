@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+func TestSimilar(t *testing.T) {
+	model, err := recommender.ReadModel("./data/")
+	if err != nil {
+		t.Fatalf("Unable to read model: %v", err)
+	}
+	recs, err := model.Similar("tensorflow/tensorflow", 10)
+	if err != nil {
+		t.Fatalf("Failed to find similar repositories: %v", err)
+	}
+	if len(recs) != 10 {
+		t.Errorf("Wrong number of similar repositories: %v", recs)
+	}
+	for i, rec := range recs {
+		if rec.Repository == "tensorflow/tensorflow" {
+			t.Errorf("Repository is similar to itself")
+		}
+		if rec.Score > 1+1e-9 || (i > 0 && rec.Score > recs[i-1].Score) {
+			t.Errorf("Wrong scores: %v", recs)
+		}
+	}
+	if _, err := model.Similar("unknown/repository", 10); err == nil {
+		t.Errorf("Expected error for unknown repository")
+	}
+}
+
+func TestSimilarHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/similar/BVLC/caffe", nil)
+	similar(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status: %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Repositories similar to") {
+		t.Errorf("Wrong page: %s", w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != publicCacheControl {
+		t.Errorf("Similar page is not cacheable")
+	}
+
+	w = httptest.NewRecorder()
+	similar(w, httptest.NewRequest("GET", "/similar/unknown/repository", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Wrong status for unknown repository: %d", w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+// Keyboard shortcuts for the recommendations list:
+// j/k move the selection, o opens, s stars and x dismisses the selected
+// repository.
+(function () {
+  var items = Array.prototype.slice.call(document.querySelectorAll("#recs > li"));
+  var selected = -1;
+
+  function select(i) {
+    if (items.length === 0) {
+      return;
+    }
+    i = Math.max(0, Math.min(items.length - 1, i));
+    if (selected >= 0) {
+      items[selected].classList.remove("selected");
+    }
+    selected = i;
+    items[selected].classList.add("selected");
+    items[selected].scrollIntoView({block: "nearest"});
+  }
+
+  function post(action, item) {
+    var body = new URLSearchParams();
+    body.set("repo", item.getAttribute("data-repository"));
+    return fetch(action, {
+      method: "POST",
+      body: body,
+      credentials: "same-origin",
+      headers: {"Accept": "application/json", "X-Requested-With": "fetch"}
+    }).then(function (resp) {
+      if (resp.ok) {
+        return resp;
+      }
+      return resp.json().then(function (err) {
+        if (err.login_url && window.confirm(err.error + ". Grant it now?")) {
+          window.location = err.login_url;
+        }
+        throw new Error(err.error);
+      });
+    });
+  }
+
+  var actions = {
+    j: function () { select(selected + 1); },
+    k: function () { select(selected - 1); },
+    o: function (item) { window.open(item.querySelector("a").href); },
+    s: function (item) {
+      post("/star", item).then(function () { item.classList.add("starred"); });
+    },
+    x: function (item) {
+      post("/dismiss", item).then(function () {
+        var i = items.indexOf(item);
+        item.parentNode.removeChild(item);
+        items.splice(i, 1);
+        selected = -1;
+        select(i);
+      });
+    }
+  };
+
+  document.addEventListener("keydown", function (e) {
+    var tag = e.target.tagName;
+    if (e.ctrlKey || e.metaKey || e.altKey || tag === "INPUT" || tag === "TEXTAREA") {
+      return;
+    }
+    var action = actions[e.key];
+    if (!action) {
+      return;
+    }
+    if (e.key !== "j" && e.key !== "k" && selected < 0) {
+      return;
+    }
+    e.preventDefault();
+    action(items[selected]);
+  });
+})();
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jbochi/github-recs/lru"
+	"github.com/jbochi/github-recs/recommender"
+)
+
+type (
+	statusResponse struct {
+		Model *modelStatus `json:"model"`
+		// the message shown during maintenance
+		Maintenance string `json:"maintenance,omitempty"`
+		// whether the models are still loading at startup, and why they
+		// failed to
+		Loading   bool   `json:"loading,omitempty"`
+		LoadError string `json:"load_error,omitempty"`
+		// the share of the repositories read while they load in shards,
+		// and whether the model served only has those read so far
+		LoadProgress float64 `json:"load_progress,omitempty"`
+		Partial      bool    `json:"partial,omitempty"`
+		// the size, hits and evictions of the in-memory caches, by name
+		Caches map[string]lru.Stats `json:"caches"`
+		// every version, when there is more than one
+		Versions []*modelStatus `json:"versions,omitempty"`
+	}
+
+	modelStatus struct {
+		Version string `json:"version"`
+		// the share of the users assigned to the version
+		Weight              int   `json:"weight"`
+		Repositories        int   `json:"repositories"`
+		Factors             int   `json:"factors"`
+		MemoryBytes         int64 `json:"memory_bytes"`
+		MemoryBudgetBytes   int64 `json:"memory_budget_bytes,omitempty"`
+		MemoryHeadroomBytes int64 `json:"memory_headroom_bytes,omitempty"`
+		// whether recommendations search an index instead of scoring
+		// every repository
+		Indexed bool `json:"indexed"`
+	}
+)
+
+// status reports the state of the loaded model as JSON, or why there is
+// none, with a 503
+func status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", privateCacheControl)
+	model := currentModel()
+	response := statusResponse{Maintenance: maintenanceMessage, Caches: cacheStats(model)}
+	modelLoad.Lock()
+	response.Loading = modelLoad.loading
+	if modelLoad.loading {
+		response.LoadProgress = modelLoad.progress
+	}
+	response.Partial = modelLoad.partial
+	if modelLoad.err != nil {
+		response.LoadError = modelLoad.err.Error()
+	}
+	modelLoad.Unlock()
+	if len(modelVersions) > 1 {
+		for _, v := range modelVersions {
+			if m := versionModel(v.name); m != nil {
+				response.Versions = append(response.Versions, newModelStatus(m, v))
+			}
+		}
+	}
+	if model == nil {
+		if response.Loading {
+			w.Header().Set("Retry-After", strconv.Itoa(warmingUpRetryAfter))
+		}
+		writeJSON(w, r, http.StatusServiceUnavailable, response)
+		return
+	}
+	response.Model = newModelStatus(model, modelVersions[0])
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// cacheStats returns the stats of the caches, with the queries of the
+// model if there is one
+func cacheStats(m *recommender.Model) map[string]lru.Stats {
+	stats := map[string]lru.Stats{
+		"sessions":     sessions.Stats(),
+		"summaries":    summaries.summaries.Stats(),
+		"idempotency":  idempotentResponses.responses.Stats(),
+		"repositories": repositoryInfos.Stats(),
+		"public_stars": publicStars.Stats(),
+	}
+	if m != nil {
+		stats["queries"] = m.QueryCacheStats()
+	}
+	return stats
+}
+
+func newModelStatus(m *recommender.Model, v modelVersion) *modelStatus {
+	s := &modelStatus{
+		Version:      v.name,
+		Weight:       v.weight,
+		Repositories: m.NumRepositories(),
+		Factors:      m.Factors(),
+		MemoryBytes:  m.MemoryFootprint(),
+		Indexed:      m.Indexed(),
+	}
+	if modelMemoryBudget > 0 {
+		s.MemoryBudgetBytes = modelMemoryBudget
+		s.MemoryHeadroomBytes = modelMemoryBudget - s.MemoryBytes
+	}
+	return s
+}
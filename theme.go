@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type (
+	// theme brands the pages of a deployment, so companies running the
+	// recommender for inner-source discovery do not have to fork the
+	// templates. It is set with the THEME_* environment variables.
+	theme struct {
+		ProductName string
+		// URL of an image shown before the product name
+		LogoURL string
+		// CSS colors of the navigation bar and of links and buttons
+		NavbarColor  string
+		PrimaryColor string
+		FooterLinks  []themeLink
+	}
+
+	themeLink struct {
+		Label string
+		URL   string
+	}
+)
+
+var (
+	defaultTheme = theme{ProductName: "GitHub Repository Recommender"}
+	currentTheme = defaultTheme
+
+	cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+)
+
+// loadTheme reads the theme from the environment:
+//
+//	THEME_PRODUCT_NAME   replaces "GitHub Repository Recommender"
+//	THEME_LOGO_URL       an image shown in the navigation bar
+//	THEME_NAVBAR_COLOR   e.g. #24292e
+//	THEME_PRIMARY_COLOR  e.g. navy
+//	THEME_FOOTER_LINKS   e.g. "Help|https://wiki.example.com, Privacy|/privacy"
+func loadTheme(getenv func(string) string) (theme, error) {
+	t := defaultTheme
+	if name := strings.TrimSpace(getenv("THEME_PRODUCT_NAME")); name != "" {
+		t.ProductName = name
+	}
+	t.LogoURL = strings.TrimSpace(getenv("THEME_LOGO_URL"))
+	for _, c := range []struct {
+		name  string
+		color *string
+	}{
+		{"THEME_NAVBAR_COLOR", &t.NavbarColor},
+		{"THEME_PRIMARY_COLOR", &t.PrimaryColor},
+	} {
+		value := strings.TrimSpace(getenv(c.name))
+		if value != "" && !cssColor.MatchString(value) {
+			return t, fmt.Errorf("Invalid %s %q, expected a hex or named CSS color", c.name, value)
+		}
+		*c.color = value
+	}
+	for _, link := range splitPatterns(getenv("THEME_FOOTER_LINKS")) {
+		parts := strings.SplitN(link, "|", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return t, fmt.Errorf("Invalid THEME_FOOTER_LINKS entry %q, expected label|url", link)
+		}
+		t.FooterLinks = append(t.FooterLinks, themeLink{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+	}
+	return t, nil
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadTheme(t *testing.T) {
+	env := map[string]string{
+		"THEME_PRODUCT_NAME":  "Example Discover",
+		"THEME_NAVBAR_COLOR":  "#24292e",
+		"THEME_PRIMARY_COLOR": "navy",
+		"THEME_FOOTER_LINKS":  "Help|https://wiki.example.com, Privacy|/privacy",
+	}
+	got, err := loadTheme(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("Failed to load theme: %v", err)
+	}
+	want := theme{
+		ProductName:  "Example Discover",
+		NavbarColor:  "#24292e",
+		PrimaryColor: "navy",
+		FooterLinks:  []themeLink{{"Help", "https://wiki.example.com"}, {"Privacy", "/privacy"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadTheme = %+v, want %+v", got, want)
+	}
+
+	if got, _ := loadTheme(func(string) string { return "" }); !reflect.DeepEqual(got, defaultTheme) {
+		t.Errorf("Default theme = %+v", got)
+	}
+	for name, value := range map[string]string{
+		"THEME_PRIMARY_COLOR": "red; background: url(x)",
+		"THEME_FOOTER_LINKS":  "no url",
+	} {
+		if _, err := loadTheme(func(n string) string {
+			if n == name {
+				return value
+			}
+			return ""
+		}); err == nil {
+			t.Errorf("Expected error for %s=%q", name, value)
+		}
+	}
+}
+
+func TestThemeIsRendered(t *testing.T) {
+	defer func(saved theme) { currentTheme = saved }(currentTheme)
+	currentTheme = theme{ProductName: "Example Discover", NavbarColor: "#24292e", FooterLinks: []themeLink{{"Help", "/help"}}}
+
+	w := httptest.NewRecorder()
+	similar(w, httptest.NewRequest("GET", "/similar/BVLC/caffe", nil))
+	body := w.Body.String()
+	for _, want := range []string{"<title>Example Discover</title>", "#24292e", `<a href="/help">Help</a>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Page does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+const (
+	// defaultModelVersion names the single model of modelDir when
+	// MODEL_VERSIONS is not set
+	defaultModelVersion = "default"
+	// modelVersionHeader selects the model version of a request, and
+	// tells which one served it
+	modelVersionHeader = "Model-Version"
+)
+
+// modelVersion is a model served side by side with others, from its own
+// subdirectory of modelDir. Signed in users are assigned to a version at
+// random, in proportion to its weight, and stay with it.
+type modelVersion struct {
+	name   string
+	weight int
+}
+
+// modelVersions are the versions served, set with MODEL_VERSIONS. The
+// first is the default one, which anonymous requests use.
+var modelVersions = []modelVersion{{defaultModelVersion, 1}}
+
+// parseModelVersions parses a comma separated list of versions, each with
+// an optional weight, as in "v1:90,v2:10". Versions default to a weight
+// of 1, and a weight of 0 serves a version only to requests that ask for
+// it.
+func parseModelVersions(s string) ([]modelVersion, error) {
+	var versions []modelVersion
+	seen := map[string]bool{}
+	total := 0
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v := modelVersion{field, 1}
+		if i := strings.LastIndex(field, ":"); i >= 0 {
+			weight, err := strconv.Atoi(field[i+1:])
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight of %q", field)
+			}
+			v = modelVersion{field[:i], weight}
+		}
+		if v.name == "" || strings.ContainsAny(v.name, `/\.`) {
+			return nil, fmt.Errorf("invalid version name %q", v.name)
+		}
+		if seen[v.name] {
+			return nil, fmt.Errorf("duplicate version %q", v.name)
+		}
+		seen[v.name] = true
+		total += v.weight
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no versions")
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("all versions have a weight of 0")
+	}
+	return versions, nil
+}
+
+// modelVersionPath returns the directory a version is loaded from
+func modelVersionPath(version string) string {
+	if len(modelVersions) == 1 && version == defaultModelVersion {
+		return modelDir
+	}
+	return modelDir + version + "/"
+}
+
+func findModelVersion(name string) (modelVersion, bool) {
+	for _, v := range modelVersions {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return modelVersion{}, false
+}
+
+// modelForRequest returns the model a request is served with, and its
+// version: the one in its Model-Version header, or else the one its user
+// is assigned to. Anonymous requests get the default version, so shared
+// caches can keep their responses.
+func modelForRequest(r *http.Request) (*recommender.Model, string) {
+	version := r.Header.Get(modelVersionHeader)
+	if _, ok := findModelVersion(version); !ok {
+		version = assignModelVersion(tokenDigest(r), modelVersions)
+	}
+	return versionModel(version), version
+}
+
+// assignModelVersion picks the version of a user by hashing their id
+// into the weights of the versions, so a user always gets the same one
+func assignModelVersion(user string, versions []modelVersion) string {
+	if user == "" || len(versions) == 1 {
+		return versions[0].name
+	}
+	total := 0
+	for _, v := range versions {
+		total += v.weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(user))
+	bucket := int(h.Sum32() % uint32(total))
+	for _, v := range versions {
+		if bucket < v.weight {
+			return v.name
+		}
+		bucket -= v.weight
+	}
+	return versions[0].name
+}
+
+// setModelVersionHeader tells which version served a response
+func setModelVersionHeader(w http.ResponseWriter, version string) {
+	w.Header().Set(modelVersionHeader, version)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jbochi/github-recs/recommender"
+)
+
+func TestParseModelVersions(t *testing.T) {
+	versions, err := parseModelVersions("v1:90, v2:10,v3")
+	if want := []modelVersion{{"v1", 90}, {"v2", 10}, {"v3", 1}}; err != nil || !reflect.DeepEqual(versions, want) {
+		t.Errorf("parseModelVersions: %v %v", versions, err)
+	}
+	for _, s := range []string{"", "v1:x", "v1:-1", "v1,v1", "../v1", ":1", "v1:0"} {
+		if _, err := parseModelVersions(s); err == nil {
+			t.Errorf("%q was accepted", s)
+		}
+	}
+}
+
+func TestAssignModelVersion(t *testing.T) {
+	versions := []modelVersion{{"v1", 90}, {"v2", 10}, {"v3", 0}}
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		user := fmt.Sprint("user", i)
+		version := assignModelVersion(user, versions)
+		if assignModelVersion(user, versions) != version {
+			t.Fatalf("%s was assigned to different versions", user)
+		}
+		counts[version]++
+	}
+	if counts["v2"] < 800 || counts["v2"] > 1200 || counts["v3"] != 0 {
+		t.Errorf("Assignments do not follow the weights: %v", counts)
+	}
+	if v := assignModelVersion("", versions); v != "v1" {
+		t.Errorf("Anonymous user was assigned to %s", v)
+	}
+}
+
+func TestModelForRequest(t *testing.T) {
+	defer func(saved []modelVersion) { modelVersions = saved }(modelVersions)
+	defer setVersionModel("v2", nil)
+	modelVersions = []modelVersion{{defaultModelVersion, 1}, {"v2", 0}}
+	v2 := &recommender.Model{}
+	setVersionModel("v2", v2)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "token", Value: "secret"})
+	if m, version := modelForRequest(r); m != currentModel() || version != defaultModelVersion {
+		t.Errorf("Request was served by %s", version)
+	}
+	r.Header.Set(modelVersionHeader, "v2")
+	if m, version := modelForRequest(r); m != v2 || version != "v2" {
+		t.Errorf("Request for v2 was served by %s", version)
+	}
+	r.Header.Set(modelVersionHeader, "unknown")
+	if _, version := modelForRequest(r); version != defaultModelVersion {
+		t.Errorf("Request for an unknown version was served by %s", version)
+	}
+}
+
+func TestPagesOfUsersUseTheirModelVersion(t *testing.T) {
+	defer func(saved []modelVersion) { modelVersions = saved }(modelVersions)
+	defer setVersionModel("v2", nil)
+	modelVersions = []modelVersion{{defaultModelVersion, 1}, {"v2", 0}}
+	setVersionModel("v2", currentModel())
+	server, client, stop := startDevServer(t, newDevGitHub(time.Now()))
+	defer stop()
+	signIn(t, server, client, "ml-researcher")
+
+	for _, path := range []string{"/check?repo=golang/go", "/stars", "/api/v1/stars", "/export?format=bookmarks"} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set(modelVersionHeader, "v2")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(modelVersionHeader) != "v2" {
+			t.Errorf("%s was served by %q: %d", path, resp.Header.Get(modelVersionHeader), resp.StatusCode)
+		}
+	}
+}